@@ -0,0 +1,76 @@
+package rabbitmonit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+apiPath builds a management api path out of the given segments, escaping each of them
+so vhosts like "/" end up as %2F
+*/
+func apiPath(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return strings.Join(escaped, "/")
+}
+
+/*
+get performs a GET request against the management api and decodes the json answer in out
+
+the request is bound to ctx so cancellation and deadlines abort it. all the failures are reported as *APIError
+*/
+func (p *Ops) get(ctx context.Context, op, path string, out interface{}) error {
+	endpoint := strings.TrimRight(p.Host, "/") + "/api/" + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return &APIError{Op: op, Path: path, Err: err}
+	}
+	creds, err := p.credentials(ctx)
+	if err != nil {
+		return &APIError{Op: op, Path: path, Err: err}
+	}
+
+	req.SetBasicAuth(creds.Login, creds.Password)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", p.userAgent())
+
+	client, err := p.apiClient()
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return &APIError{Op: op, Path: path, Kind: kindFromError(err), Err: err}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &APIError{
+			Op:         op,
+			Path:       path,
+			StatusCode: res.StatusCode,
+			Kind:       kindFromStatus(res.StatusCode),
+			Err:        errors.New(strings.TrimSpace(string(body))),
+		}
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		kind := kindFromError(err)
+		if kind == nil {
+			kind = ErrMalformed
+		}
+		return &APIError{Op: op, Path: path, StatusCode: res.StatusCode, Kind: kind, Err: err}
+	}
+	return nil
+}
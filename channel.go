@@ -0,0 +1,190 @@
+package rabbitmonit
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+ChannelProperties extends ChannelInfo with additional alerting/status values
+*/
+type ChannelProperties struct {
+	Stats       ChannelStats
+	Error       ChannelAlert
+	Warning     ChannelAlert
+	ChannelInfo ChannelInfo
+	Cluster     string             // name of the cluster the channel belongs to, set by Fleet
+	Thresholds  *ChannelThresholds // the limits used for alerting, nil means DefaultChannelThresholds
+
+	// SaturatedPolls is the number of consecutive earlier polls during which the channel had as many
+	// unacked messages as its prefetch allows. it is kept by ChannelTracker, 0 without one
+	SaturatedPolls int
+}
+
+/*
+ChannelStats holds the statistics calculated for a channel
+*/
+type ChannelStats struct {
+	Unacked        int     // messages delivered and not acknowledged yet
+	Prefetch       int     // effective prefetch limit, 0 means unlimited
+	PrefetchUsage  float64 // percentage of the prefetch limit used by the unacked messages
+	Unconfirmed    int     // published messages not confirmed by the broker yet
+	AckRate        float64 // messages acknowledged per second
+	DeliverRate    float64 // messages delivered per second
+	PublishRate    float64 // messages published per second
+	SaturatedPolls int     // consecutive polls, this one included, with the prefetch limit reached
+}
+
+/*
+ChannelAlert contains all the alerts that a channel can report
+*/
+type ChannelAlert struct {
+	Has               bool // do we have any errors
+	UnboundedPrefetch bool // the channel consumes without a prefetch limit
+	Saturated         bool // the unacked messages stay at the prefetch limit
+}
+
+/*
+Kinds returns the names of the flags that are set, Has excluded
+*/
+func (ca ChannelAlert) Kinds() []string {
+	var kinds []string
+	if ca.UnboundedPrefetch {
+		kinds = append(kinds, "unbounded_prefetch")
+	}
+	if ca.Saturated {
+		kinds = append(kinds, "saturated")
+	}
+	return kinds
+}
+
+/*
+Calculate runs all the statistics on the current channel
+*/
+func (cp *ChannelProperties) Calculate() {
+	cp.Error = ChannelAlert{}
+	cp.Warning = ChannelAlert{}
+	cp.Stats = ChannelStats{}
+
+	cp.calculateStats().
+		alertUnboundedPrefetch().
+		alertSaturated()
+}
+
+/*
+thresholds returns the limits to be used for the current channel
+*/
+func (cp *ChannelProperties) thresholds() ChannelThresholds {
+	if cp.Thresholds != nil {
+		return *cp.Thresholds
+	}
+	return DefaultChannelThresholds()
+}
+
+/*
+calculateStats fills in the unacked messages against the prefetch limit and the rates.
+
+the effective prefetch is the per consumer limit multiplied by the consumers of the channel, capped by
+the per channel limit when there is one
+*/
+func (cp *ChannelProperties) calculateStats() *ChannelProperties {
+	info := cp.ChannelInfo
+	cp.Stats.Unacked = info.MessagesUnack
+	cp.Stats.Unconfirmed = info.MessagesUnconfirmed
+	cp.Stats.AckRate = info.MessageStats.AckDetails.Rate
+	cp.Stats.DeliverRate = info.MessageStats.DeliverGetDetails.Rate
+	cp.Stats.PublishRate = info.MessageStats.PublishDetails.Rate
+
+	prefetch := info.PrefetchCount * info.ConsumerCount
+	if info.GlobalPrefetchCount > 0 && (prefetch == 0 || info.GlobalPrefetchCount < prefetch) {
+		prefetch = info.GlobalPrefetchCount
+	}
+	cp.Stats.Prefetch = prefetch
+
+	if prefetch > 0 {
+		cp.Stats.PrefetchUsage = RoundPlus(float64(info.MessagesUnack)/float64(prefetch)*100, 2)
+		if info.MessagesUnack >= prefetch {
+			cp.Stats.SaturatedPolls = cp.SaturatedPolls + 1
+		}
+	}
+	return cp
+}
+
+/*
+alertUnboundedPrefetch raises a warning when a channel consumes without any prefetch limit, the broker
+pushes it every ready message no matter how slow the consumers are
+*/
+func (cp *ChannelProperties) alertUnboundedPrefetch() *ChannelProperties {
+	if cp.ChannelInfo.ConsumerCount > 0 && cp.Stats.Prefetch == 0 {
+		cp.Warning.Has = true
+		cp.Warning.UnboundedPrefetch = true
+	}
+	return cp
+}
+
+/*
+alertSaturated raises a warning when the unacked messages of the channel reached its prefetch limit
+for SaturatedPolls (default 3) consecutive polls, its consumers do not keep up
+*/
+func (cp *ChannelProperties) alertSaturated() *ChannelProperties {
+	th := cp.thresholds()
+	if th.SaturatedPolls > 0 && cp.Stats.SaturatedPolls >= th.SaturatedPolls {
+		cp.Warning.Has = true
+		cp.Warning.Saturated = true
+	}
+	return cp
+}
+
+/*
+ChannelTracker remembers for how many consecutive polls every channel has been saturated, so
+alertSaturated can tell a burst from consumers that do not keep up. ChannelTracker is safe for
+concurrent use
+*/
+type ChannelTracker struct {
+	mu        sync.Mutex
+	saturated map[string]int
+}
+
+/*
+NewChannelTracker returns an empty tracker
+*/
+func NewChannelTracker() *ChannelTracker {
+	return &ChannelTracker{saturated: make(map[string]int)}
+}
+
+/*
+Track calculates the given channels again with the saturated polls seen so far and returns them sorted
+worst first. every call is expected to carry all the tracked channels, the ones missing are forgotten
+*/
+func (t *ChannelTracker) Track(channels []ChannelProperties) []ChannelProperties {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	saturated := make(map[string]int, len(channels))
+	for i := range channels {
+		cp := &channels[i]
+		key := cp.Cluster + "\x00" + cp.ChannelInfo.Name
+		cp.SaturatedPolls = t.saturated[key]
+		cp.Calculate()
+		if cp.Stats.SaturatedPolls > 0 {
+			saturated[key] = cp.Stats.SaturatedPolls
+		}
+	}
+	t.saturated = saturated
+
+	sortChannels(channels)
+	return channels
+}
+
+/*
+sortChannels sorts the channels worst first, the most unacked messages leading within a level
+*/
+func sortChannels(channels []ChannelProperties) {
+	sort.SliceStable(channels, func(i, j int) bool {
+		first, second := &channels[i], &channels[j]
+		if first.Level() != second.Level() {
+			return first.Level() > second.Level()
+		}
+		return first.Stats.Unacked > second.Stats.Unacked
+	})
+}
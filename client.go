@@ -0,0 +1,65 @@
+package rabbitmonit
+
+import (
+	"net/http"
+	"time"
+)
+
+const defaultMaxIdleConns = 10
+
+/*
+init builds the long lived http client of Ops out of its connection settings. it runs once, all the
+following calls share the same client and its connection pool
+*/
+func (p *Ops) init() {
+	p.setup.Do(func() {
+		if p.HTTPClient != nil {
+			// the supplied client replaces every other setting, including a TLS config that would not build
+			p.httpClient = p.HTTPClient
+			return
+		}
+
+		transport := p.Transport
+		if transport == nil {
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+			transport.MaxIdleConnsPerHost = defaultMaxIdleConns
+			if p.MaxIdleConns > 0 {
+				transport.MaxIdleConnsPerHost = p.MaxIdleConns
+			}
+			transport.IdleConnTimeout = 90 * time.Second
+		}
+
+		if p.TLS != nil {
+			tlsConfig, err := p.TLS.Config()
+			if err != nil {
+				p.setupErr = &APIError{Op: "TLS", Err: err}
+				return
+			}
+			transport = transport.Clone()
+			transport.TLSClientConfig = tlsConfig
+		}
+
+		p.httpClient = &http.Client{Transport: transport, Timeout: p.Timeout}
+	})
+}
+
+/*
+apiClient returns the http client used for all the management api requests
+*/
+func (p *Ops) apiClient() (*http.Client, error) {
+	p.init()
+	if p.httpClient == nil {
+		return nil, p.setupErr
+	}
+	return p.httpClient, nil
+}
+
+/*
+userAgent returns the user agent sent with every request
+*/
+func (p *Ops) userAgent() string {
+	if p.UserAgent != "" {
+		return p.UserAgent
+	}
+	return "rabbit-monit"
+}
@@ -0,0 +1,105 @@
+package rabbitmonit_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+/*
+counting is a RoundTripper counting the requests it forwards
+*/
+type counting struct {
+	next  http.RoundTripper
+	count atomic.Int32
+}
+
+func (c *counting) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.count.Add(1)
+	return c.next.RoundTrip(req)
+}
+
+/*
+listAll issues one request of each kind through ops
+*/
+func listAll(t *testing.T, ops *rabbitmonit.Ops) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := ops.ListNodesContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.ListVhostsContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.ListNodesContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInjectedHTTPClient(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{})
+	defer srv.Close()
+
+	rt := &counting{next: http.DefaultTransport}
+	ops := srv.Ops()
+	ops.HTTPClient = &http.Client{Transport: rt}
+	listAll(t, ops)
+
+	if got, want := int(rt.count.Load()), srv.TotalRequests(); got != want || got != 3 {
+		t.Errorf("%d requests went through the injected client, the server got %d, want 3", got, want)
+	}
+}
+
+func TestInjectedTransport(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{})
+	defer srv.Close()
+
+	var dials atomic.Int32
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+
+	ops := srv.Ops()
+	ops.Transport = transport
+	listAll(t, ops)
+
+	// sequential calls share the client of ops, so its single keep-alive connection is reused
+	if got := dials.Load(); got != 1 {
+		t.Errorf("the injected transport dialed %d times for 3 sequential calls, want 1", got)
+	}
+	if got := srv.TotalRequests(); got != 3 {
+		t.Errorf("the server got %d requests, want 3", got)
+	}
+}
+
+func TestClientReused(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{})
+	defer srv.Close()
+
+	var reused []bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) },
+	})
+
+	// without any injection Ops builds its client once, the later calls reuse its pooled connection
+	ops := srv.Ops()
+	for i := 0; i < 3; i++ {
+		if _, err := ops.ListNodesContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(reused) != 3 || reused[0] || !reused[1] || !reused[2] {
+		t.Errorf("connection reused = %v, want [false true true]", reused)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+finding is one entity in warning or error, as printed by analyze
+*/
+type finding struct {
+	Entity   rabbitmonit.Entity `json:"entity"`
+	Level    rabbitmonit.Level  `json:"level"`
+	Errors   []string           `json:"errors,omitempty"`
+	Warnings []string           `json:"warnings,omitempty"`
+	Override string             `json:"override,omitempty"` // the queue override rule that applied
+	Stats    interface{}        `json:"stats"`
+}
+
+/*
+runAnalyze evaluates captured management api json without a live broker, writes the findings to stdout
+and returns 1 when there is any, 2 when the input cannot be evaluated
+*/
+func runAnalyze(stdout, stderr io.Writer, args []string) int {
+	flags := flag.NewFlagSet("rabbit-monit analyze", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var files rabbitmonit.SnapshotFiles
+	flags.StringVar(&files.Queues, "queues", "", "json from /api/queues, - for stdin")
+	flags.StringVar(&files.Nodes, "nodes", "", "json from /api/nodes, - for stdin")
+	flags.StringVar(&files.Vhosts, "vhosts", "", "json from /api/vhosts, - for stdin")
+	flags.StringVar(&files.Consumers, "consumers", "", "json from /api/consumers, - for stdin")
+	flags.StringVar(&files.Connections, "connections", "", "json from /api/connections, - for stdin")
+	flags.StringVar(&files.Channels, "channels", "", "json from /api/channels, - for stdin")
+	snapshotPath := flags.String("snapshot", "", "archived ClusterSnapshot json, replaces the other inputs")
+	thresholdsPath := flags.String("thresholds", "", "thresholds file (json or yaml), defaults when empty")
+	format := flags.String("format", "text", "output format, text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var th *rabbitmonit.Thresholds
+	if *thresholdsPath != "" {
+		var err error
+		if th, err = rabbitmonit.LoadThresholds(*thresholdsPath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	var snapshot *rabbitmonit.ClusterSnapshot
+	var err error
+	if *snapshotPath != "" {
+		snapshot, err = readSnapshot(*snapshotPath, th)
+	} else {
+		snapshot, err = rabbitmonit.LoadSnapshotFiles(files, th)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	findings := collectFindings(snapshot)
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		printFindings(stdout, findings)
+	}
+
+	if len(findings) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func readSnapshot(path string, th *rabbitmonit.Thresholds) (*rabbitmonit.ClusterSnapshot, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return rabbitmonit.ReadSnapshot(r, th)
+}
+
+/*
+collectFindings lists the entities in warning or error, nodes first, then vhosts, queues, connections, channels and consumers
+*/
+func collectFindings(s *rabbitmonit.ClusterSnapshot) []finding {
+	var findings []finding
+	for i := range s.NodeProperties {
+		np := &s.NodeProperties[i]
+		if np.Level() != rabbitmonit.LevelOK {
+			findings = append(findings, finding{np.Entity(), np.Level(), np.Error.Kinds(), np.Warning.Kinds(), "", np.Stats})
+		}
+	}
+	for i := range s.VhostProperties {
+		vp := &s.VhostProperties[i]
+		if vp.Level() != rabbitmonit.LevelOK {
+			findings = append(findings, finding{vp.Entity(), vp.Level(), vp.Error.Kinds(), vp.Warning.Kinds(), "", vp.Stats})
+		}
+	}
+	for i := range s.QueueProperties {
+		qp := &s.QueueProperties[i]
+		if qp.Level() != rabbitmonit.LevelOK {
+			f := finding{qp.Entity(), qp.Level(), qp.Error.Kinds(), qp.Warning.Kinds(), "", qp.Stats}
+			if qp.Override != nil {
+				f.Override = qp.Override.Name
+			}
+			findings = append(findings, f)
+		}
+	}
+	for i := range s.ConnectionProperties {
+		cp := &s.ConnectionProperties[i]
+		if cp.Level() != rabbitmonit.LevelOK {
+			findings = append(findings, finding{cp.Entity(), cp.Level(), cp.Error.Kinds(), cp.Warning.Kinds(), "", cp.Stats})
+		}
+	}
+	for i := range s.ChannelProperties {
+		cp := &s.ChannelProperties[i]
+		if cp.Level() != rabbitmonit.LevelOK {
+			findings = append(findings, finding{cp.Entity(), cp.Level(), cp.Error.Kinds(), cp.Warning.Kinds(), "", cp.Stats})
+		}
+	}
+	for i := range s.ConsumerProperties {
+		cp := &s.ConsumerProperties[i]
+		if cp.Level() != rabbitmonit.LevelOK {
+			findings = append(findings, finding{cp.Entity(), cp.Level(), cp.Error.Kinds(), cp.Warning.Kinds(), "", cp.Stats})
+		}
+	}
+	return findings
+}
+
+func printFindings(w io.Writer, findings []finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "no problems found")
+		return
+	}
+	for _, f := range findings {
+		line := fmt.Sprintf("%-7s %s", f.Level, f.Entity)
+		if len(f.Errors) > 0 {
+			line += " errors=" + strings.Join(f.Errors, ",")
+		}
+		if len(f.Warnings) > 0 {
+			line += " warnings=" + strings.Join(f.Warnings, ",")
+		}
+		if f.Override != "" {
+			line += fmt.Sprintf(" override=%q", f.Override)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
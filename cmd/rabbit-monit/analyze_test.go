@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunAnalyze(t *testing.T) {
+	dir := filepath.Join("..", "..", "testdata", "snapshot")
+	file := func(name string) string { return filepath.Join(dir, name) }
+	inputs := []string{"-queues", file("queues.json"), "-nodes", file("nodes.json"), "-vhosts", file("vhosts.json")}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		want     []string // the text lines printed
+		wantErr  string
+	}{
+		{
+			name:     "full",
+			args:     append(inputs, "-consumers", file("consumers.json")),
+			wantCode: 1,
+			want: []string{
+				"warning node rabbit@a warnings=fd",
+				"warning vhost / warnings=rdy",
+				"error   queue //stuck errors=unack",
+				"warning queue //slow warnings=rdy,utilisation",
+			},
+		},
+		{
+			name:     "no consumers",
+			args:     inputs,
+			wantCode: 1,
+			want: []string{
+				"warning node rabbit@a warnings=fd",
+				"warning vhost / warnings=rdy",
+				"warning queue //slow warnings=rdy,utilisation",
+			},
+		},
+		{name: "no problem", args: []string{"-nodes", file("nodes.json"), "-vhosts", file("vhosts.json"), "-thresholds", file("relaxed.yml")}, wantCode: 0, want: []string{"no problems found"}},
+		{name: "missing thresholds", args: []string{"-thresholds", file("missing.yml")}, wantCode: 2, wantErr: "missing.yml"},
+		{name: "missing file", args: []string{"-queues", file("missing.json")}, wantCode: 2, wantErr: "missing.json"},
+		{name: "malformed json", args: []string{"-queues", file("malformed.json")}, wantCode: 2, wantErr: "malformed.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runAnalyze(&stdout, &stderr, tt.args); code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d, stderr %q", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("stderr = %q, want it to mention %q", stderr.String(), tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			if got := strings.Split(strings.TrimSpace(stdout.String()), "\n"); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("stdout:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestRunAnalyzeJSON(t *testing.T) {
+	dir := filepath.Join("..", "..", "testdata", "snapshot")
+	var stdout, stderr bytes.Buffer
+	code := runAnalyze(&stdout, &stderr, []string{"-format", "json", "-queues", filepath.Join(dir, "queues.json"), "-consumers", filepath.Join(dir, "consumers.json")})
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1, stderr %q", code, stderr.String())
+	}
+
+	var findings []finding
+	if err := json.Unmarshal(stdout.Bytes(), &findings); err != nil {
+		t.Fatal(err)
+	}
+	levels := make(map[string]string)
+	for _, f := range findings {
+		levels[f.Entity.String()] = f.Level.String()
+	}
+	if levels["queue //stuck"] != "error" || levels["queue //slow"] != "warning" || len(levels) != 2 {
+		t.Errorf("levels = %v, want stuck in error and slow in warning", levels)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+nagios plugin exit codes
+*/
+const (
+	exitOK       = 0
+	exitWarning  = 1
+	exitCritical = 2
+	exitUnknown  = 3
+)
+
+/*
+maxProblems is the number of problems listed on the status line, the others are only counted
+*/
+const maxProblems = 10
+
+/*
+checkResult accumulates the outcome of a check
+*/
+type checkResult struct {
+	level    rabbitmonit.Level
+	errors   []string // entities in error, in discovery order
+	warnings []string // entities in warning, in discovery order
+	perfdata []string
+}
+
+/*
+add records the level of an entity
+*/
+func (r *checkResult) add(entity rabbitmonit.Entity, level rabbitmonit.Level, errs, warnings []string) {
+	if level > r.level {
+		r.level = level
+	}
+	switch level {
+	case rabbitmonit.LevelError:
+		r.errors = append(r.errors, fmt.Sprintf("%s error [%s]", entity, strings.Join(errs, ",")))
+	case rabbitmonit.LevelWarning:
+		r.warnings = append(r.warnings, fmt.Sprintf("%s warning [%s]", entity, strings.Join(warnings, ",")))
+	}
+}
+
+/*
+perf appends a perfdata value
+*/
+func (r *checkResult) perf(label string, value interface{}, uom string) {
+	r.perfdata = append(r.perfdata, fmt.Sprintf("'%s'=%v%s", label, value, uom))
+}
+
+/*
+runCheck runs a single check, writes its status line to w and returns the nagios exit code
+*/
+func runCheck(w io.Writer, args []string) int {
+	flags := flag.NewFlagSet("rabbit-monit check", flag.ContinueOnError)
+	connection := addConnectionFlags(flags)
+	vhost := flags.String("vhost", "", "only check this vhost")
+	queue := flags.String("queue", "", "only check this queue, requires -vhost")
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(w, "RABBITMONIT UNKNOWN - %v\n", err)
+		return exitUnknown
+	}
+
+	if *queue != "" && *vhost == "" {
+		fmt.Fprintln(w, "RABBITMONIT UNKNOWN - -queue requires -vhost")
+		return exitUnknown
+	}
+
+	cfg, err := connection.load()
+	if err != nil {
+		fmt.Fprintf(w, "RABBITMONIT UNKNOWN - %v\n", err)
+		return exitUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout.Duration)
+	defer cancel()
+
+	result, err := check(ctx, cfg.ops(), *vhost, *queue)
+	if err != nil {
+		fmt.Fprintf(w, "RABBITMONIT UNKNOWN - %v\n", err)
+		return exitUnknown
+	}
+	return result.print(w)
+}
+
+/*
+check evaluates the cluster, a vhost or a single queue
+*/
+func check(ctx context.Context, ops *rabbitmonit.Ops, vhost, queue string) (*checkResult, error) {
+	result := &checkResult{}
+
+	if queue != "" {
+		qp, err := ops.GetQueueContext(ctx, vhost, queue)
+		if err != nil {
+			return nil, err
+		}
+		result.add(qp.Entity(), qp.Level(), qp.Error.Kinds(), qp.Warning.Kinds())
+		result.perf("ready", qp.QueueInfo.MessagesRdy, "")
+		result.perf("unacked", qp.QueueInfo.MessagesUnack, "")
+		result.perf("utilisation", qp.Stats.Utilisation, "")
+		return result, nil
+	}
+
+	if vhost == "" {
+		nodes, err := ops.ListNodesContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var fd, mem float64
+		for _, np := range nodes {
+			result.add(np.Entity(), np.Level(), np.Error.Kinds(), np.Warning.Kinds())
+			fd = maxFloat(fd, np.Stats.FdUsedPercentage)
+			mem = maxFloat(mem, np.Stats.MemUsedPercentage)
+		}
+		result.perf("fd", fd, "%")
+		result.perf("mem", mem, "%")
+
+		vhosts, err := ops.ListVhostsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, vp := range vhosts {
+			result.add(vp.Entity(), vp.Level(), vp.Error.Kinds(), vp.Warning.Kinds())
+		}
+	} else {
+		info, err := ops.GetVhostContext(ctx, vhost)
+		if err != nil {
+			return nil, err
+		}
+		vp := rabbitmonit.EvaluateVhosts([]rabbithole.VhostInfo{info}, ops.Thresholds)[0]
+		result.add(vp.Entity(), vp.Level(), vp.Error.Kinds(), vp.Warning.Kinds())
+	}
+
+	var queues []rabbitmonit.QueueProperties
+	var err error
+	if vhost == "" {
+		queues, err = ops.ListAccumulationQueuesContext(ctx)
+	} else {
+		queues, err = ops.ListQueuesContext(ctx, vhost)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ready, unacked int
+	utilisation := 100.0
+	for _, qp := range queues {
+		result.add(qp.Entity(), qp.Level(), qp.Error.Kinds(), qp.Warning.Kinds())
+		ready += qp.QueueInfo.MessagesRdy
+		unacked += qp.QueueInfo.MessagesUnack
+		if qp.QueueInfo.Consumers > 0 && qp.Stats.Utilisation < utilisation {
+			utilisation = qp.Stats.Utilisation
+		}
+	}
+	result.perf("ready", ready, "")
+	result.perf("unacked", unacked, "")
+	result.perf("utilisation", utilisation, "")
+
+	return result, nil
+}
+
+/*
+print writes the nagios status line and returns the matching exit code
+*/
+func (r *checkResult) print(w io.Writer) int {
+	status, code := "OK", exitOK
+	switch r.level {
+	case rabbitmonit.LevelError:
+		status, code = "CRITICAL", exitCritical
+	case rabbitmonit.LevelWarning:
+		status, code = "WARNING", exitWarning
+	}
+
+	problems := len(r.errors) + len(r.warnings)
+	summary := "no problems found"
+	if problems > 0 {
+		listed := make([]string, 0, maxProblems+1)
+		listed = append(listed, r.errors...)
+		listed = append(listed, r.warnings...)
+		if len(listed) > maxProblems {
+			listed = append(listed[:maxProblems], fmt.Sprintf("and %d more", problems-maxProblems))
+		}
+		summary = fmt.Sprintf("%d problem(s): %s", problems, strings.Join(listed, "; "))
+	}
+	fmt.Fprintf(w, "RABBITMONIT %s - %s | %s\n", status, summary, strings.Join(r.perfdata, " "))
+	return code
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+func queueEntity(i int) rabbitmonit.Entity {
+	return rabbitmonit.Entity{Type: "queue", Vhost: "/", Queue: fmt.Sprintf("q%d", i)}
+}
+
+func TestCheckResultPrint(t *testing.T) {
+	tests := []struct {
+		name     string
+		errors   int
+		warnings int
+		want     string
+	}{
+		{"no problem", 0, 0, "RABBITMONIT OK - no problems found | \n"},
+		{"warnings only", 0, 1, "RABBITMONIT WARNING - 1 problem(s): queue //q0 warning [rdy] | \n"},
+		{"errors before warnings", 2, 1, "RABBITMONIT CRITICAL - 3 problem(s): queue //q1 error [rdy]; queue //q2 error [rdy]; queue //q0 warning [rdy] | \n"},
+		{"capped", maxProblems, 5, "; queue //q14 error [rdy]; and 5 more | \n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &checkResult{}
+			// warnings are discovered first to check that errors are still listed before them
+			i := 0
+			for ; i < tt.warnings; i++ {
+				r.add(queueEntity(i), rabbitmonit.LevelWarning, nil, []string{"rdy"})
+			}
+			for ; i < tt.warnings+tt.errors; i++ {
+				r.add(queueEntity(i), rabbitmonit.LevelError, []string{"rdy"}, nil)
+			}
+
+			var out bytes.Buffer
+			r.print(&out)
+			if !strings.HasSuffix(out.String(), tt.want) {
+				t.Errorf("print = %q, want it to end with %q", out.String(), tt.want)
+			}
+			if listed := strings.Count(out.String(), "queue //q"); listed > maxProblems {
+				t.Errorf("%d problems listed, want at most %d", listed, maxProblems)
+			}
+		})
+	}
+}
+
+func TestRunCheckArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"queue without vhost", []string{"-host", "http://localhost:15672", "-queue", "orders"}, "RABBITMONIT UNKNOWN - -queue requires -vhost\n"},
+		{"unknown flag", []string{"-nope"}, "RABBITMONIT UNKNOWN - flag provided but not defined: -nope\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runCheck(&out, tt.args); code != exitUnknown {
+				t.Errorf("exit code = %d, want %d", code, exitUnknown)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/notify"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+config is the yaml configuration of the daemon
+*/
+type config struct {
+	Host     string   `yaml:"host"`     // management api endpoint, eg. http://localhost:15672
+	Login    string   `yaml:"login"`    // user allowed to read the statistics
+	Password string   `yaml:"password"` // password of the user
+	Interval duration `yaml:"interval"` // time between two polls
+	Timeout  duration `yaml:"timeout"`  // upper bound for a single poll
+	Listen   string   `yaml:"listen"`   // address of the health endpoint
+
+	TLS         *rabbitmonit.TLSConfig `yaml:"tls"`         // settings for https management endpoints
+	Credentials *credentialsConfig     `yaml:"credentials"` // replaces login/password
+
+	Thresholds   rabbitmonit.Thresholds `yaml:"thresholds"`
+	Webhooks     []webhookConfig        `yaml:"webhooks"`
+	Alertmanager *alertmanagerConfig    `yaml:"alertmanager"`
+
+	provider rabbitmonit.CredentialProvider
+}
+
+/*
+credentialsConfig selects where the management api credentials come from. exactly one source must be set
+*/
+type credentialsConfig struct {
+	Env *struct {
+		LoginVar    string `yaml:"login_var"`
+		PasswordVar string `yaml:"password_var"`
+	} `yaml:"env"`
+	File *struct {
+		Login        string `yaml:"login"`
+		LoginFile    string `yaml:"login_file"`
+		PasswordFile string `yaml:"password_file"`
+	} `yaml:"file"`
+	Netrc *struct {
+		Path    string `yaml:"path"`
+		Machine string `yaml:"machine"` // defaults to the host name of the management api
+	} `yaml:"netrc"`
+	Exec *struct {
+		Command []string `yaml:"command"`
+		TTL     duration `yaml:"ttl"`
+	} `yaml:"exec"`
+}
+
+/*
+provider builds the credential provider described by the configuration
+*/
+func (c *credentialsConfig) provider(host string) (rabbitmonit.CredentialProvider, error) {
+	var providers []rabbitmonit.CredentialProvider
+	if c.Env != nil {
+		providers = append(providers, rabbitmonit.EnvCredentials{LoginVar: c.Env.LoginVar, PasswordVar: c.Env.PasswordVar})
+	}
+	if c.File != nil {
+		if c.File.PasswordFile == "" {
+			return nil, errors.New("config: credentials.file.password_file is required")
+		}
+		providers = append(providers, &rabbitmonit.FileCredentials{Login: c.File.Login, LoginFile: c.File.LoginFile, PasswordFile: c.File.PasswordFile})
+	}
+	if c.Netrc != nil {
+		machine := c.Netrc.Machine
+		if machine == "" {
+			u, err := url.Parse(host)
+			if err != nil {
+				return nil, err
+			}
+			machine = u.Hostname()
+		}
+		providers = append(providers, rabbitmonit.NetrcCredentials{Path: c.Netrc.Path, Machine: machine})
+	}
+	if c.Exec != nil {
+		if len(c.Exec.Command) == 0 {
+			return nil, errors.New("config: credentials.exec.command is required")
+		}
+		providers = append(providers, &rabbitmonit.ExecCredentials{Command: c.Exec.Command, TTL: c.Exec.TTL.Duration})
+	}
+
+	if len(providers) != 1 {
+		return nil, errors.New("config: credentials needs exactly one of env, file, netrc or exec")
+	}
+	return providers[0], nil
+}
+
+/*
+alertmanagerConfig describes the alertmanager receiving the active alerts after every poll
+*/
+type alertmanagerConfig struct {
+	URL    string            `yaml:"url"`
+	Labels map[string]string `yaml:"labels"`
+	TTL    duration          `yaml:"ttl"` // defaults to three intervals
+}
+
+/*
+webhookConfig describes a webhook receiving the alert transitions
+*/
+type webhookConfig struct {
+	URL        string            `yaml:"url"`
+	Template   string            `yaml:"template"` // empty for the default json payload, "slack", "teams" or a template
+	Headers    map[string]string `yaml:"headers"`
+	MaxRetries *int              `yaml:"max_retries"`
+	Backoff    duration          `yaml:"backoff"`
+}
+
+/*
+duration allows writing durations as "30s" in the configuration
+*/
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+/*
+defaultConfig returns the configuration used for everything missing from the file
+*/
+func defaultConfig() *config {
+	return &config{
+		Interval:   duration{30 * time.Second},
+		Timeout:    duration{20 * time.Second},
+		Listen:     ":9419",
+		Thresholds: rabbitmonit.DefaultThresholds(),
+	}
+}
+
+/*
+loadConfig reads the configuration file, filling in the defaults for everything missing
+*/
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Host == "" {
+		return nil, errors.New("config: host is required")
+	}
+	if cfg.Interval.Duration <= 0 {
+		return nil, errors.New("config: interval must be positive")
+	}
+	if cfg.Timeout.Duration <= 0 {
+		return nil, errors.New("config: timeout must be positive")
+	}
+	if err := cfg.Thresholds.Compile(); err != nil {
+		return nil, err
+	}
+	if err := cfg.resolveCredentials(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+/*
+connectionFlags are the flags of the one shot subcommands selecting the management api
+*/
+type connectionFlags struct {
+	config   *string
+	host     *string
+	login    *string
+	password *string
+}
+
+/*
+addConnectionFlags registers the connection flags
+*/
+func addConnectionFlags(flags *flag.FlagSet) *connectionFlags {
+	return &connectionFlags{
+		config:   flags.String("config", "", "optional configuration file providing the connection and the thresholds"),
+		host:     flags.String("host", "", "management api endpoint, eg. http://localhost:15672"),
+		login:    flags.String("login", "", "user allowed to read the statistics"),
+		password: flags.String("password", "", "password of the user"),
+	}
+}
+
+/*
+load reads the configuration file when there is one and applies the flags on top of it
+*/
+func (f *connectionFlags) load() (*config, error) {
+	cfg := defaultConfig()
+	if *f.config != "" {
+		var err error
+		if cfg, err = loadConfig(*f.config); err != nil {
+			return nil, err
+		}
+	}
+	for dst, src := range map[*string]string{&cfg.Host: *f.host, &cfg.Login: *f.login, &cfg.Password: *f.password} {
+		if src != "" {
+			*dst = src
+		}
+	}
+	if cfg.Host == "" {
+		return nil, errors.New("-host or a configuration file is required")
+	}
+	return cfg, nil
+}
+
+/*
+resolveCredentials builds the credential provider once so its caches live as long as the process
+*/
+func (c *config) resolveCredentials() error {
+	if c.Credentials == nil {
+		return nil
+	}
+	provider, err := c.Credentials.provider(c.Host)
+	if err != nil {
+		return err
+	}
+	c.provider = provider
+	return nil
+}
+
+/*
+ops builds the Ops described by the configuration
+*/
+func (c *config) ops() *rabbitmonit.Ops {
+	return &rabbitmonit.Ops{
+		Host:        c.Host,
+		Login:       c.Login,
+		Password:    c.Password,
+		Thresholds:  &c.Thresholds,
+		TLS:         c.TLS,
+		Credentials: c.provider,
+	}
+}
+
+/*
+notifiers builds the notifiers described by the configuration
+*/
+func (c *config) notifiers() ([]notify.Notifier, error) {
+	var notifiers []notify.Notifier
+	for _, wc := range c.Webhooks {
+		webhook, err := notify.NewWebhook(wc.URL, wc.Template)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Headers = wc.Headers
+		webhook.Backoff = wc.Backoff.Duration
+		if wc.MaxRetries != nil {
+			webhook.MaxRetries = *wc.MaxRetries
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	return notifiers, nil
+}
+
+/*
+alertmanager builds the alertmanager client described by the configuration, nil if there is none
+*/
+func (c *config) alertmanager() *notify.Alertmanager {
+	if c.Alertmanager == nil || c.Alertmanager.URL == "" {
+		return nil
+	}
+
+	ttl := c.Alertmanager.TTL.Duration
+	if ttl <= 0 {
+		ttl = 3 * c.Interval.Duration
+	}
+	return &notify.Alertmanager{
+		URL:    c.Alertmanager.URL,
+		Labels: c.Alertmanager.Labels,
+		TTL:    ttl,
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigDurations(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"defaults", "host: http://localhost:15672\n", ""},
+		{"zero interval", "host: http://localhost:15672\ninterval: 0s\n", "interval must be positive"},
+		{"negative interval", "host: http://localhost:15672\ninterval: -1s\n", "interval must be positive"},
+		{"zero timeout", "host: http://localhost:15672\ntimeout: 0s\n", "timeout must be positive"},
+		{"negative timeout", "host: http://localhost:15672\ntimeout: -5s\n", "timeout must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := loadConfig(path)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("loadConfig: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("loadConfig error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+queueReport is the consumer health of one queue, as printed by the consumers subcommand
+*/
+type queueReport struct {
+	Vhost     string            `json:"vhost"`
+	Queue     string            `json:"queue"`
+	Level     rabbitmonit.Level `json:"level"`
+	Consumers []consumerReport  `json:"consumers"`
+}
+
+/*
+consumerReport describes one consumer of a queue
+*/
+type consumerReport struct {
+	Tag         string                    `json:"tag"`
+	Channel     string                    `json:"channel"`
+	Peer        string                    `json:"peer"`
+	AckRequired bool                      `json:"ack_required"`
+	Prefetch    int                       `json:"prefetch"`
+	Exclusive   bool                      `json:"exclusive"`
+	Level       rabbitmonit.Level         `json:"level"`
+	Errors      []string                  `json:"errors,omitempty"`
+	Warnings    []string                  `json:"warnings,omitempty"`
+	Stats       rabbitmonit.ConsumerStats `json:"stats"`
+}
+
+/*
+runConsumers prints the consumer health report of the cluster or of a vhost
+*/
+func runConsumers(args []string) int {
+	flags := flag.NewFlagSet("rabbit-monit consumers", flag.ContinueOnError)
+	connection := addConnectionFlags(flags)
+	vhost := flags.String("vhost", "", "only report this vhost")
+	format := flags.String("format", "text", "output format, text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := connection.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout.Duration)
+	defer cancel()
+
+	consumers, err := cfg.ops().ListConsumersContext(ctx, *vhost)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report := buildConsumerReport(rabbitmonit.GroupConsumers(consumers))
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	} else {
+		printConsumerReport(os.Stdout, report)
+	}
+
+	for _, qr := range report {
+		if qr.Level != rabbitmonit.LevelOK {
+			return 1
+		}
+	}
+	return 0
+}
+
+func buildConsumerReport(queues []rabbitmonit.QueueConsumers) []queueReport {
+	report := make([]queueReport, 0, len(queues))
+	for i := range queues {
+		qc := &queues[i]
+		qr := queueReport{Vhost: qc.Vhost, Queue: qc.Queue, Level: qc.Level()}
+		for j := range qc.Consumers {
+			cp := &qc.Consumers[j]
+			info := cp.ConsumerInfo
+			qr.Consumers = append(qr.Consumers, consumerReport{
+				Tag:         info.ConsumerTag,
+				Channel:     info.ChannelDetails.Name,
+				Peer:        fmt.Sprintf("%s:%d", info.ChannelDetails.PeerHost, info.ChannelDetails.PeerPort),
+				AckRequired: bool(info.AcknowledgementMode),
+				Prefetch:    info.PrefetchCount,
+				Exclusive:   info.Exclusive,
+				Level:       cp.Level(),
+				Errors:      cp.Error.Kinds(),
+				Warnings:    cp.Warning.Kinds(),
+				Stats:       cp.Stats,
+			})
+		}
+		report = append(report, qr)
+	}
+	return report
+}
+
+func printConsumerReport(w io.Writer, report []queueReport) {
+	if len(report) == 0 {
+		fmt.Fprintln(w, "no consumers")
+		return
+	}
+	for _, qr := range report {
+		fmt.Fprintf(w, "%-7s queue %s/%s, %d consumer(s)\n", qr.Level, qr.Vhost, qr.Queue, len(qr.Consumers))
+		for _, c := range qr.Consumers {
+			ack := "manual"
+			if !c.AckRequired {
+				ack = "auto"
+			}
+			line := fmt.Sprintf("  %-7s %s channel=%q peer=%s ack=%s prefetch=%d exclusive=%t", c.Level, c.Tag, c.Channel, c.Peer, ack, c.Prefetch, c.Exclusive)
+			if len(c.Errors) > 0 {
+				line += " errors=" + strings.Join(c.Errors, ",")
+			}
+			if len(c.Warnings) > 0 {
+				line += " warnings=" + strings.Join(c.Warnings, ",")
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
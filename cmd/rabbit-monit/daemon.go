@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/notify"
+)
+
+// notifyBacklog is the number of polls whose events can wait for the notifier worker
+const notifyBacklog = 16
+
+/*
+coreListings are the listings the health endpoint depends on. the others are reported but a failure
+does not make the daemon unhealthy
+*/
+var coreListings = []string{"nodes", "vhosts", "queues"}
+
+/*
+daemon polls the cluster on an interval and logs every alert transition.
+
+a poll runs the listings one after another, all of them bounded by the poll timeout, and evaluates them
+on the polling goroutine. the events are handed to a single notifier worker through a backlog of
+notifyBacklog polls, the events of a poll are dropped with a log line when the backlog is full
+*/
+type daemon struct {
+	cfg       *config
+	ops       *rabbitmonit.Ops
+	alerts    *rabbitmonit.AlertStore
+	channels  *rabbitmonit.ChannelTracker
+	notifiers []notify.Notifier
+	manager   *notify.Alertmanager
+	pending   chan []rabbitmonit.Event // events of the polls waiting for the notifier worker
+
+	mu       sync.Mutex
+	listings map[string]listing // outcome of every listing, by name
+}
+
+/*
+listing is the outcome of the last attempts of a management api listing
+*/
+type listing struct {
+	LastSuccess time.Time `json:"last_success"`
+	Err         error     `json:"-"`
+}
+
+func newDaemon(cfg *config) (*daemon, error) {
+	notifiers, err := cfg.notifiers()
+	if err != nil {
+		return nil, err
+	}
+
+	return &daemon{
+		cfg:       cfg,
+		ops:       cfg.ops(),
+		alerts:    rabbitmonit.NewAlertStore(),
+		channels:  rabbitmonit.NewChannelTracker(),
+		notifiers: notifiers,
+		manager:   cfg.alertmanager(),
+		pending:   make(chan []rabbitmonit.Event, notifyBacklog),
+		listings:  make(map[string]listing),
+	}, nil
+}
+
+/*
+run polls until ctx is done
+*/
+func (d *daemon) run(ctx context.Context) {
+	if len(d.notifiers) > 0 {
+		go d.notifyLoop(ctx)
+	}
+
+	ticker := time.NewTicker(d.cfg.Interval.Duration)
+	defer ticker.Stop()
+
+	for {
+		d.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+poll runs one round of Nodes, Vhosts, AccumulationQueues, Exchanges, Connections and Channels. a listing that fails is not
+observed, so its entities keep their alerts until the next successful poll
+*/
+func (d *daemon) poll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout.Duration)
+	defer cancel()
+
+	var events []rabbitmonit.Event
+	outcomes := make(map[string]error)
+	now := time.Now()
+
+	nodes, err := d.ops.ListNodesContext(ctx)
+	if outcomes["nodes"] = err; err == nil {
+		events = append(events, d.alerts.ObserveNodes(now, nodes)...)
+	}
+
+	vhosts, err := d.ops.ListVhostsContext(ctx)
+	if outcomes["vhosts"] = err; err == nil {
+		events = append(events, d.alerts.ObserveVhosts(now, vhosts)...)
+	}
+
+	queues, err := d.ops.ListAccumulationQueuesContext(ctx)
+	if outcomes["queues"] = err; err == nil {
+		events = append(events, d.alerts.ObserveQueues(now, queues)...)
+	}
+
+	exchanges, err := d.ops.ListExchangesContext(ctx, "")
+	if outcomes["exchanges"] = err; err == nil {
+		events = append(events, d.alerts.ObserveExchanges(now, exchanges)...)
+	}
+
+	connections, err := d.ops.ListConnectionsContext(ctx, "")
+	if outcomes["connections"] = err; err == nil {
+		events = append(events, d.alerts.ObserveConnections(now, connections)...)
+	}
+
+	channels, err := d.ops.ListChannelsContext(ctx, "")
+	if outcomes["channels"] = err; err == nil {
+		events = append(events, d.alerts.ObserveChannels(now, d.channels.Track(channels))...)
+	}
+
+	for _, event := range events {
+		logEvent(event)
+	}
+	if len(events) > 0 && len(d.notifiers) > 0 {
+		select {
+		case d.pending <- events:
+		default:
+			log.Printf("notify: %d events dropped, the notifiers are %d polls behind", len(events), notifyBacklog)
+		}
+	}
+	if d.manager != nil {
+		if err := d.manager.Sync(ctx, now, d.alerts.Active()); err != nil {
+			log.Printf("alertmanager: %v", err)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for name, err := range outcomes {
+		l := d.listings[name]
+		l.Err = err
+		if err == nil {
+			l.LastSuccess = now
+		} else {
+			log.Printf("poll: listing %s failed: %v", name, err)
+		}
+		d.listings[name] = l
+	}
+}
+
+/*
+notifyLoop is the single notifier worker. it runs outside of the poll loop so a slow receiver does
+not delay the next poll, and on its own so the events reach every notifier in order
+*/
+func (d *daemon) notifyLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case events := <-d.pending:
+			d.notify(events)
+		}
+	}
+}
+
+/*
+notify hands the events to all the notifiers, in order
+*/
+func (d *daemon) notify(events []rabbitmonit.Event) {
+	for _, notifier := range d.notifiers {
+		for _, event := range events {
+			ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Interval.Duration)
+			if err := notifier.Notify(ctx, event); err != nil {
+				log.Printf("notify %s: %v", event.Entity, err)
+			}
+			cancel()
+		}
+	}
+}
+
+/*
+logEvent writes a single line describing the transition
+*/
+func logEvent(e rabbitmonit.Event) {
+	log.Printf("%s %s: %s -> %s [%s] for %s",
+		e.Entity, e.Type, e.Previous, e.Level, strings.Join(e.Kinds, ","), e.Duration.Round(time.Second))
+}
+
+/*
+ServeHTTP is the health endpoint. it fails when one of the core listings did not succeed in the last three
+intervals, and reports the listings whose last attempt failed
+*/
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	listings := make(map[string]listing, len(d.listings))
+	for name, l := range d.listings {
+		listings[name] = l
+	}
+	d.mu.Unlock()
+
+	// last_poll is the last time every core listing succeeded
+	var lastPoll time.Time
+	healthy := true
+	for i, name := range coreListings {
+		success := listings[name].LastSuccess
+		if i == 0 || success.Before(lastPoll) {
+			lastPoll = success
+		}
+		if success.IsZero() || time.Since(success) >= 3*d.cfg.Interval.Duration {
+			healthy = false
+		}
+	}
+
+	failed := make(map[string]string)
+	for name, l := range listings {
+		if l.Err != nil {
+			failed[name] = l.Err.Error()
+		}
+	}
+
+	body := map[string]interface{}{
+		"healthy":   healthy,
+		"last_poll": lastPoll,
+		"listings":  listings,
+	}
+	if len(failed) > 0 {
+		body["failed"] = failed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+/*
+health polls once and returns the status and the body of the health endpoint
+*/
+func health(t *testing.T, d *daemon) (int, map[string]interface{}) {
+	t.Helper()
+	d.poll(context.Background())
+
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body
+}
+
+func failedListings(body map[string]interface{}) []string {
+	failed, _ := body["failed"].(map[string]interface{})
+	var names []string
+	for _, name := range []string{"nodes", "vhosts", "queues", "exchanges", "connections", "channels"} {
+		if _, ok := failed[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestDaemonHealth(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{})
+	defer srv.Close()
+
+	newTestDaemon := func() *daemon {
+		cfg := defaultConfig()
+		cfg.Host, cfg.Login, cfg.Password = srv.URL, fakeapi.Login, fakeapi.Password
+		d, err := newDaemon(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name       string
+		fail       []string // the api paths failing during the poll
+		fresh      bool     // whether the poll runs on a daemon that never polled
+		wantStatus int
+		wantFailed []string
+	}{
+		{name: "every listing", fresh: true, wantStatus: http.StatusOK},
+		{name: "optional listing failing", fresh: true, fail: []string{"/api/exchanges", "/api/channels"}, wantStatus: http.StatusOK, wantFailed: []string{"exchanges", "channels"}},
+		{name: "core listing failing", fresh: true, fail: []string{"/api/queues"}, wantStatus: http.StatusServiceUnavailable, wantFailed: []string{"queues"}},
+		// a core listing that succeeded recently keeps the daemon healthy, the failure is still reported
+		{name: "core listing failing once", fail: []string{"/api/nodes"}, wantStatus: http.StatusOK, wantFailed: []string{"nodes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDaemon()
+			if !tt.fresh {
+				if status, _ := health(t, d); status != http.StatusOK {
+					t.Fatalf("first poll status = %d, want 200", status)
+				}
+			}
+
+			for _, path := range tt.fail {
+				srv.Fail(path, fakeapi.Failure{Status: http.StatusInternalServerError})
+				defer srv.Recover(path)
+			}
+
+			status, body := health(t, d)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %v", status, tt.wantStatus, body)
+			}
+			if got := failedListings(body); !reflect.DeepEqual(got, tt.wantFailed) {
+				t.Errorf("failed listings = %v, want %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
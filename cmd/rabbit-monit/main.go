@@ -0,0 +1,75 @@
+/*
+Command rabbit-monit monitors a rabbitmq cluster.
+
+without a subcommand it runs as a daemon: it polls the cluster, logs alert state changes and exposes
+a health endpoint. the check subcommand runs a single nagios/icinga compatible check and the analyze
+subcommand evaluates management api json captured earlier, without a live broker. the consumers
+subcommand prints the consumer health report of every queue and the topology subcommand exports the
+message flow as a graph
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Stdout, os.Args[2:]))
+		case "analyze":
+			os.Exit(runAnalyze(os.Stdout, os.Stderr, os.Args[2:]))
+		case "consumers":
+			os.Exit(runConsumers(os.Args[2:]))
+		case "topology":
+			os.Exit(runTopology(os.Args[2:]))
+		case "daemon":
+			runDaemon(os.Args[2:])
+			return
+		}
+	}
+	runDaemon(os.Args[1:])
+}
+
+/*
+runDaemon polls the cluster until interrupted
+*/
+func runDaemon(args []string) {
+	flags := flag.NewFlagSet("rabbit-monit", flag.ExitOnError)
+	configPath := flags.String("config", "rabbit-monit.yml", "path to the configuration file")
+	flags.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("cannot load configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d, err := newDaemon(cfg)
+	if err != nil {
+		log.Fatalf("cannot start: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/health", d)
+	server := &http.Server{Addr: cfg.Listen, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("health endpoint: %v", err)
+		}
+	}()
+
+	log.Printf("monitoring %s every %s", cfg.Host, cfg.Interval.Duration)
+	d.run(ctx)
+
+	server.Shutdown(context.Background())
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+runTopology exports the exchanges, bindings and queues of the cluster or of a vhost as a graph
+*/
+func runTopology(args []string) int {
+	flags := flag.NewFlagSet("rabbit-monit topology", flag.ContinueOnError)
+	connection := addConnectionFlags(flags)
+	vhost := flags.String("vhost", "", "only export this vhost")
+	format := flags.String("format", "dot", "output format, dot, mermaid or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	write, ok := map[string]func(io.Writer, []rabbitmonit.Topology) error{
+		"dot":     rabbitmonit.WriteTopologyDOT,
+		"mermaid": rabbitmonit.WriteTopologyMermaid,
+		"json":    rabbitmonit.WriteTopologyJSON,
+	}[*format]
+	if !ok {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := connection.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout.Duration)
+	defer cancel()
+
+	topologies, err := cfg.ops().GetTopologyContext(ctx, *vhost)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := write(os.Stdout, topologies); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,172 @@
+package rabbitmonit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+CollectOptions tunes CollectQueues
+*/
+type CollectOptions struct {
+	Vhosts            []string // the vhosts to collect, all of them when empty
+	Workers           int      // vhosts collected in parallel, defaults to 4
+	RequestsPerSecond float64  // upper bound for the management api requests issued by the collection, 0 means no limit
+}
+
+/*
+QueueCollection is the outcome of CollectQueues
+*/
+type QueueCollection struct {
+	Queues []QueueProperties // the queues of all the vhosts that could be collected, worst first
+	Failed map[string]error  // the vhosts that could not be collected
+}
+
+/*
+Complete reports whether every vhost was collected
+*/
+func (qc *QueueCollection) Complete() bool {
+	return len(qc.Failed) == 0
+}
+
+/*
+CollectQueues collects the queues of many vhosts in parallel with a bounded number of workers and
+an optional rate limit, so a full cluster snapshot is fast without overloading the broker.
+
+a failing vhost does not stop the collection, it is reported in QueueCollection.Failed. the error is
+only returned when the vhosts themselves cannot be listed
+*/
+func (p *Ops) CollectQueues(ctx context.Context, opts CollectOptions) (*QueueCollection, error) {
+	limiter := newRateLimiter(opts.RequestsPerSecond)
+
+	vhosts := opts.Vhosts
+	if len(vhosts) == 0 {
+		if err := limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		var infos []rabbithole.VhostInfo
+		if err := p.get(ctx, "CollectQueues", "vhosts", &infos); err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			vhosts = append(vhosts, info.Name)
+		}
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	collection := &QueueCollection{Failed: make(map[string]error)}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for vhost := range jobs {
+				queues, err := p.collectVhost(ctx, limiter, vhost)
+
+				mu.Lock()
+				if err != nil {
+					collection.Failed[vhost] = err
+				} else {
+					collection.Queues = append(collection.Queues, queues...)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, vhost := range vhosts {
+		jobs <- vhost
+	}
+	close(jobs)
+	wg.Wait()
+
+	qs := &queueSorter{}
+	qs.Sort(collection.Queues)
+
+	return collection, nil
+}
+
+/*
+collectVhost lists the queues and the consumers of a vhost and calculates the queue properties
+*/
+func (p *Ops) collectVhost(ctx context.Context, limiter *rateLimiter, vhost string) ([]QueueProperties, error) {
+	if err := limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	var queues []rabbithole.QueueInfo
+	if err := p.get(ctx, "CollectQueues", "queues/"+apiPath(vhost), &queues); err != nil {
+		return nil, err
+	}
+
+	if err := limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	consumers, err := p.consumerInfosIn(ctx, vhost)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.queueProperties(queues, NewConsumerIndex(consumers)), nil
+}
+
+/*
+rateLimiter spaces requests evenly, a nil limiter never waits
+*/
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+/*
+newRateLimiter returns a limiter allowing perSecond requests per second, nil when perSecond is not positive
+*/
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+/*
+wait blocks until the next request is allowed or ctx is done
+*/
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if r == nil {
+		return ctx.Err()
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	slot := r.next
+	if slot.Before(now) {
+		slot = now
+	}
+	r.next = slot.Add(r.interval)
+	r.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,142 @@
+package rabbitmonit_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+/*
+inflight is a RoundTripper recording how many requests run at once and when each one started
+*/
+type inflight struct {
+	mu      sync.Mutex
+	current int
+	max     int
+	started []time.Time
+}
+
+func (f *inflight) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.current++
+	if f.current > f.max {
+		f.max = f.current
+	}
+	f.started = append(f.started, time.Now())
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.current--
+		f.mu.Unlock()
+	}()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func collectFixtures(vhosts ...string) fakeapi.Fixtures {
+	var f fakeapi.Fixtures
+	for _, vhost := range vhosts {
+		f.Vhosts = append(f.Vhosts, rabbithole.VhostInfo{Name: vhost})
+		f.Queues = append(f.Queues, rabbithole.QueueInfo{Name: "work", Vhost: vhost, State: "running", Durable: true})
+	}
+	return f
+}
+
+func collectOps(srv *fakeapi.Server, rt http.RoundTripper) *rabbitmonit.Ops {
+	ops := srv.Ops()
+	ops.HTTPClient = &http.Client{Transport: rt}
+	return ops
+}
+
+func TestCollectQueuesWorkers(t *testing.T) {
+	var vhosts []string
+	for i := 0; i < 6; i++ {
+		vhosts = append(vhosts, fmt.Sprintf("/v%d", i))
+	}
+	srv := fakeapi.NewServer(collectFixtures(vhosts...))
+	defer srv.Close()
+	for _, vhost := range vhosts {
+		srv.Fail("/api/queues/"+url.PathEscape(vhost), fakeapi.Failure{Delay: 50 * time.Millisecond})
+	}
+
+	rt := &inflight{}
+	collection, err := collectOps(srv, rt).CollectQueues(context.Background(), rabbitmonit.CollectOptions{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !collection.Complete() || len(collection.Queues) != len(vhosts) {
+		t.Fatalf("collected %d queues, failed %v, want one queue per vhost", len(collection.Queues), collection.Failed)
+	}
+	if rt.max > 2 {
+		t.Errorf("%d requests ran at once, want at most 2 workers", rt.max)
+	}
+	if rt.max < 2 {
+		t.Errorf("at most %d request ran at once, want the 2 workers used in parallel", rt.max)
+	}
+}
+
+func TestCollectQueuesRateLimit(t *testing.T) {
+	srv := fakeapi.NewServer(collectFixtures("/a", "/b", "/c"))
+	defer srv.Close()
+
+	const interval = 50 * time.Millisecond
+	rt := &inflight{}
+	opts := rabbitmonit.CollectOptions{Vhosts: []string{"/a", "/b", "/c"}, Workers: 3, RequestsPerSecond: float64(time.Second / interval)}
+	begin := time.Now()
+	if _, err := collectOps(srv, rt).CollectQueues(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(begin)
+
+	// every vhost costs a queue and a consumer listing
+	if len(rt.started) != 6 {
+		t.Fatalf("got %d requests, want 6", len(rt.started))
+	}
+	sort.Slice(rt.started, func(i, j int) bool { return rt.started[i].Before(rt.started[j]) })
+	for i, started := range rt.started {
+		// the slots are spaced from the start of the collection, a late worker must not let the next one run early
+		if at := started.Sub(begin); at < time.Duration(i)*interval {
+			t.Errorf("request %d started after %s, want at least %s", i, at, time.Duration(i)*interval)
+		}
+	}
+	if elapsed > 20*interval {
+		t.Errorf("the collection took %s, want about %s", elapsed, 5*interval)
+	}
+}
+
+func TestCollectQueuesFailedVhost(t *testing.T) {
+	srv := fakeapi.NewServer(collectFixtures("/a", "/bad", "/c"))
+	defer srv.Close()
+	srv.Fail("/api/queues/%2Fbad", fakeapi.Failure{Status: http.StatusInternalServerError})
+
+	collection, err := srv.Ops().CollectQueues(context.Background(), rabbitmonit.CollectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.Complete() || len(collection.Failed) != 1 || collection.Failed["/bad"] == nil {
+		t.Errorf("failed = %v, want only /bad", collection.Failed)
+	}
+
+	var got []string
+	for _, qp := range collection.Queues {
+		got = append(got, qp.QueueInfo.Vhost)
+	}
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "/a" || got[1] != "/c" {
+		t.Errorf("queues of %v, want the ones of /a and /c", got)
+	}
+
+	srv.Fail("/api/vhosts", fakeapi.Failure{Status: http.StatusInternalServerError})
+	if _, err := srv.Ops().CollectQueues(context.Background(), rabbitmonit.CollectOptions{}); err == nil {
+		t.Error("CollectQueues succeeded although the vhosts could not be listed")
+	}
+}
@@ -0,0 +1,181 @@
+package rabbitmonit
+
+import (
+	"time"
+)
+
+/*
+ConnectionProperties extends ConnectionInfo with additional alerting/status values
+*/
+type ConnectionProperties struct {
+	Stats          ConnectionStats
+	Error          ConnectionAlert
+	Warning        ConnectionAlert
+	ConnectionInfo ConnectionInfo
+	Cluster        string                // name of the cluster the connection belongs to, set by Fleet
+	Now            time.Time             // reference time for the connection age, zero means time.Now()
+	Thresholds     *ConnectionThresholds // the limits used for alerting, nil means DefaultConnectionThresholds
+}
+
+/*
+ConnectionStats holds the statistics calculated for a connection
+*/
+type ConnectionStats struct {
+	Age      time.Duration // time since the connection was opened
+	Channels int           // open channels
+	RecvRate float64       // bytes received per second
+	SendRate float64       // bytes sent per second
+	Idle     bool          // no channel open or less traffic than IdleRate, heartbeats alone stay below it
+}
+
+/*
+ConnectionAlert contains all the alerts that a connection can report
+*/
+type ConnectionAlert struct {
+	Has         bool // do we have any errors
+	Blocked     bool // the broker blocks (error) or is about to block (warning) the publishers of the connection
+	Flow        bool // the connection is throttled by flow control
+	Channels    bool // too many channels are open on the connection
+	NoHeartbeat bool // heartbeats are disabled, dead peers are only noticed by the tcp stack
+	Idle        bool // an old connection doing nothing
+}
+
+/*
+Kinds returns the names of the flags that are set, Has excluded
+*/
+func (ca ConnectionAlert) Kinds() []string {
+	var kinds []string
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{ca.Blocked, "blocked"},
+		{ca.Flow, "flow"},
+		{ca.Channels, "channels"},
+		{ca.NoHeartbeat, "no_heartbeat"},
+		{ca.Idle, "idle"},
+	} {
+		if flag.set {
+			kinds = append(kinds, flag.name)
+		}
+	}
+	return kinds
+}
+
+/*
+Calculate runs all the statistics on the current connection
+*/
+func (cp *ConnectionProperties) Calculate() {
+	cp.Error = ConnectionAlert{}
+	cp.Warning = ConnectionAlert{}
+	cp.Stats = ConnectionStats{}
+
+	cp.calculateStats().
+		alertBlocked().
+		alertFlow().
+		alertChannels().
+		alertHeartbeat().
+		alertIdle()
+}
+
+/*
+thresholds returns the limits to be used for the current connection
+*/
+func (cp *ConnectionProperties) thresholds() ConnectionThresholds {
+	if cp.Thresholds != nil {
+		return *cp.Thresholds
+	}
+	return DefaultConnectionThresholds()
+}
+
+/*
+calculateStats fills in the age, the channels and the traffic of the connection
+*/
+func (cp *ConnectionProperties) calculateStats() *ConnectionProperties {
+	info := cp.ConnectionInfo
+	now := cp.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if info.ConnectedAt > 0 {
+		cp.Stats.Age = now.Sub(time.UnixMilli(info.ConnectedAt))
+	}
+	cp.Stats.Channels = info.Channels
+	cp.Stats.RecvRate = info.RecvOctDetails.Rate
+	cp.Stats.SendRate = info.SendOctDetails.Rate
+	cp.Stats.Idle = info.Channels == 0 || cp.Stats.RecvRate+cp.Stats.SendRate < cp.thresholds().IdleRate
+	return cp
+}
+
+/*
+alertBlocked raises an error when the connection is blocked by a memory or disk alarm, its publishers
+are stuck. it raises a warning when the connection is blocking, it will be blocked on its next publish
+*/
+func (cp *ConnectionProperties) alertBlocked() *ConnectionProperties {
+	switch cp.ConnectionInfo.State {
+	case "blocked":
+		cp.Error.Has = true
+		cp.Error.Blocked = true
+	case "blocking":
+		cp.Warning.Has = true
+		cp.Warning.Blocked = true
+	}
+	return cp
+}
+
+/*
+alertFlow raises a warning when the connection is throttled by flow control, the broker cannot keep up
+with its publishers
+*/
+func (cp *ConnectionProperties) alertFlow() *ConnectionProperties {
+	if cp.ConnectionInfo.State == "flow" {
+		cp.Warning.Has = true
+		cp.Warning.Flow = true
+	}
+	return cp
+}
+
+/*
+alertChannels raises an alert/warning when too many channels are open, usually a channel leak
+
+threshold for alert is ChannelsError (default 1000)
+
+threshold for warning is ChannelsWarning (default 100)
+*/
+func (cp *ConnectionProperties) alertChannels() *ConnectionProperties {
+	th := cp.thresholds()
+	if cp.Stats.Channels > th.ChannelsError {
+		cp.Error.Has = true
+		cp.Error.Channels = true
+	} else if cp.Stats.Channels > th.ChannelsWarning {
+		cp.Warning.Has = true
+		cp.Warning.Channels = true
+	}
+	return cp
+}
+
+/*
+alertHeartbeat raises a warning when the client disabled the heartbeats
+*/
+func (cp *ConnectionProperties) alertHeartbeat() *ConnectionProperties {
+	if cp.ConnectionInfo.Timeout == 0 {
+		cp.Warning.Has = true
+		cp.Warning.NoHeartbeat = true
+	}
+	return cp
+}
+
+/*
+alertIdle raises a warning when an idle connection is older than IdleHours (default 24), it holds
+resources on the broker for nothing. a connection is idle without any channel or when its traffic stays below
+IdleRate bytes per second (default 10), which the heartbeats alone never reach
+*/
+func (cp *ConnectionProperties) alertIdle() *ConnectionProperties {
+	th := cp.thresholds()
+	if th.IdleHours > 0 && cp.Stats.Idle && cp.Stats.Age.Hours() > th.IdleHours {
+		cp.Warning.Has = true
+		cp.Warning.Idle = true
+	}
+	return cp
+}
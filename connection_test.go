@@ -0,0 +1,85 @@
+package rabbitmonit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+func TestListConnections(t *testing.T) {
+	connectedAt := time.Now().Add(-time.Minute).UnixNano() / int64(time.Millisecond)
+	srv := fakeapi.NewServer(fakeapi.Fixtures{
+		Connections: []rabbitmonit.ConnectionInfo{
+			{Name: "10.0.0.1:5000 -> 10.0.0.2:5672", Vhost: "/", State: "running", Timeout: 60, ConnectedAt: connectedAt},
+			{Name: "10.0.0.3:5000 -> 10.0.0.2:5672", Vhost: "/billing", State: "blocked", Timeout: 60, ConnectedAt: connectedAt},
+		},
+	})
+	defer srv.Close()
+	ops := srv.Ops()
+
+	all, err := ops.ListConnections("")
+	if err != nil {
+		t.Fatalf("ListConnections: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("got %d connections, want 2", len(all))
+	}
+	if all[0].ConnectionInfo.Vhost != "/billing" || !all[0].Error.Blocked {
+		t.Errorf("first connection = %s raising %v, want the blocked one first", all[0].ConnectionInfo.Name, all[0].Error.Kinds())
+	}
+
+	billing, err := ops.ListConnections("/billing")
+	if err != nil {
+		t.Fatalf("ListConnections(/billing): %v", err)
+	}
+	if len(billing) != 1 || billing[0].ConnectionInfo.Vhost != "/billing" {
+		t.Errorf("got %d connections for /billing, want only its own", len(billing))
+	}
+	if got := srv.Requests("/api/vhosts/%2Fbilling/connections"); got != 1 {
+		t.Errorf("Requests(/api/vhosts/%%2Fbilling/connections) = %d, want 1", got)
+	}
+}
+
+func TestConnectionIdle(t *testing.T) {
+	now := time.Now()
+	custom := rabbitmonit.DefaultConnectionThresholds()
+	custom.IdleRate = 0.5
+
+	tests := []struct {
+		name       string
+		channels   int
+		rate       float64 // bytes per second in each direction
+		thresholds *rabbitmonit.ConnectionThresholds
+		want       bool
+	}{
+		{name: "no channel", channels: 0, rate: 100, want: true},
+		{name: "no traffic", channels: 1, rate: 0, want: true},
+		{name: "heartbeats only", channels: 1, rate: 0.3, want: true},
+		{name: "traffic", channels: 1, rate: 100, want: false},
+		{name: "heartbeats over a lower rate", channels: 1, rate: 0.3, thresholds: &custom, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := rabbitmonit.ConnectionProperties{
+				ConnectionInfo: rabbitmonit.ConnectionInfo{
+					Name:           "10.0.0.1:5000 -> 10.0.0.2:5672",
+					State:          "running",
+					Timeout:        60,
+					Channels:       tt.channels,
+					ConnectedAt:    now.Add(-48 * time.Hour).UnixMilli(),
+					RecvOctDetails: rabbitmonit.RateDetails{Rate: tt.rate},
+					SendOctDetails: rabbitmonit.RateDetails{Rate: tt.rate},
+				},
+				Now:        now,
+				Thresholds: tt.thresholds,
+			}
+			cp.Calculate()
+			if cp.Stats.Idle != tt.want || cp.Warning.Idle != tt.want {
+				t.Errorf("idle = %v, warning %v, want %v", cp.Stats.Idle, cp.Warning.Idle, tt.want)
+			}
+		})
+	}
+}
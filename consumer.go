@@ -0,0 +1,144 @@
+package rabbitmonit
+
+import (
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+ConsumerProperties extends rabbithole.ConsumerInfo with the queue it consumes from and additional
+alerting/status values
+*/
+type ConsumerProperties struct {
+	Stats        ConsumerStats
+	Error        ConsumerAlert
+	Warning      ConsumerAlert
+	ConsumerInfo rabbithole.ConsumerInfo
+	QueueInfo    *rabbithole.QueueInfo // the consumed queue, nil when unknown
+	Cluster      string                // name of the cluster the consumer belongs to, set by Fleet
+	Thresholds   *ConsumerThresholds   // the limits used for alerting, nil means DefaultConsumerThresholds
+}
+
+/*
+ConsumerStats holds the statistics calculated for a consumer
+*/
+type ConsumerStats struct {
+	DeliverRate    float64 // messages per second delivered to the consumer, the queue rate shared by its consumers
+	PrefetchBuffer float64 // seconds of work the prefetch holds at DeliverRate, -1 when nothing is delivered
+}
+
+/*
+ConsumerAlert contains all the alerts that a consumer can report
+*/
+type ConsumerAlert struct {
+	Has          bool // do we have any errors
+	NoAck        bool // the consumer does not acknowledge the messages of a durable queue
+	Exclusive    bool // the consumer is the only one allowed on its queue
+	PrefetchHigh bool // the prefetch is far above what the consumer processes
+}
+
+/*
+Kinds returns the names of the flags that are set, Has excluded
+*/
+func (ca ConsumerAlert) Kinds() []string {
+	var kinds []string
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{ca.NoAck, "no_ack"},
+		{ca.Exclusive, "exclusive"},
+		{ca.PrefetchHigh, "prefetch_high"},
+	} {
+		if flag.set {
+			kinds = append(kinds, flag.name)
+		}
+	}
+	return kinds
+}
+
+/*
+Calculate runs all the statistics on the current consumer
+*/
+func (cp *ConsumerProperties) Calculate() {
+	cp.Error = ConsumerAlert{}
+	cp.Warning = ConsumerAlert{}
+	cp.Stats = ConsumerStats{}
+
+	cp.calculateStats().
+		alertNoAck().
+		alertExclusive().
+		alertPrefetchHigh()
+}
+
+/*
+thresholds returns the limits to be used for the current consumer
+*/
+func (cp *ConsumerProperties) thresholds() ConsumerThresholds {
+	if cp.Thresholds != nil {
+		return *cp.Thresholds
+	}
+	return DefaultConsumerThresholds()
+}
+
+/*
+calculateStats estimates the throughput of the consumer. the management api only reports rates per
+queue, they are split evenly between the consumers of the queue
+*/
+func (cp *ConsumerProperties) calculateStats() *ConsumerProperties {
+	cp.Stats.PrefetchBuffer = -1
+	if cp.QueueInfo == nil {
+		return cp
+	}
+
+	consumers := cp.QueueInfo.Consumers
+	if consumers < 1 {
+		consumers = 1
+	}
+	cp.Stats.DeliverRate = RoundPlus(float64(cp.QueueInfo.MessageStats.DeliverDetails.Rate)/float64(consumers), 2)
+	if cp.Stats.DeliverRate > 0 {
+		cp.Stats.PrefetchBuffer = RoundPlus(float64(cp.ConsumerInfo.PrefetchCount)/cp.Stats.DeliverRate, 2)
+	}
+	return cp
+}
+
+/*
+alertNoAck raises a warning when a consumer of a durable queue does not acknowledge, the messages in
+flight are lost when it crashes
+*/
+func (cp *ConsumerProperties) alertNoAck() *ConsumerProperties {
+	if cp.QueueInfo != nil && cp.QueueInfo.Durable && !bool(cp.ConsumerInfo.AcknowledgementMode) {
+		cp.Warning.Has = true
+		cp.Warning.NoAck = true
+	}
+	return cp
+}
+
+/*
+alertExclusive raises a warning for exclusive consumers, no other consumer can take over the queue
+while they hang
+*/
+func (cp *ConsumerProperties) alertExclusive() *ConsumerProperties {
+	if cp.ConsumerInfo.Exclusive {
+		cp.Warning.Has = true
+		cp.Warning.Exclusive = true
+	}
+	return cp
+}
+
+/*
+alertPrefetchHigh raises a warning when the prefetch holds more than PrefetchBufferSeconds (default 60)
+of work, the messages wait in the consumer instead of going to the other consumers of the queue.
+prefetches up to PrefetchMinimum (default 100) and idle consumers are never flagged
+*/
+func (cp *ConsumerProperties) alertPrefetchHigh() *ConsumerProperties {
+	th := cp.thresholds()
+	if cp.Stats.PrefetchBuffer < 0 || cp.ConsumerInfo.PrefetchCount <= th.PrefetchMinimum {
+		return cp
+	}
+
+	if cp.Stats.PrefetchBuffer > th.PrefetchBufferSeconds {
+		cp.Warning.Has = true
+		cp.Warning.PrefetchHigh = true
+	}
+	return cp
+}
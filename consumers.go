@@ -0,0 +1,142 @@
+package rabbitmonit
+
+import (
+	"sort"
+
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+ConsumerIndex holds the consumers of a cluster or a vhost indexed by vhost and queue, so a single
+consumers listing can be shared by all the queues evaluated in the same poll
+*/
+type ConsumerIndex struct {
+	byQueue map[queueKey][]rabbithole.ConsumerInfo
+}
+
+/*
+queueKey identifies a queue inside a cluster
+*/
+type queueKey struct {
+	vhost string
+	name  string
+}
+
+/*
+NewConsumerIndex indexes the given consumers
+*/
+func NewConsumerIndex(consumers []rabbithole.ConsumerInfo) *ConsumerIndex {
+	ci := &ConsumerIndex{byQueue: make(map[queueKey][]rabbithole.ConsumerInfo)}
+	for _, consumer := range consumers {
+		key := queueKey{consumer.Queue.Vhost, consumer.Queue.Name}
+		ci.byQueue[key] = append(ci.byQueue[key], consumer)
+	}
+	return ci
+}
+
+/*
+Consumers returns the consumers of a queue
+*/
+func (ci *ConsumerIndex) Consumers(vhost, queue string) []rabbithole.ConsumerInfo {
+	return ci.byQueue[queueKey{vhost, queue}]
+}
+
+/*
+PrefetchTotal returns the sum of the prefetch count of all the consumers of a queue
+*/
+func (ci *ConsumerIndex) PrefetchTotal(vhost, queue string) int {
+	var total int
+	for _, consumer := range ci.Consumers(vhost, queue) {
+		total += consumer.PrefetchCount
+	}
+	return total
+}
+
+/*
+EvaluateConsumers calculates the properties of the given consumers and sorts them worst first. queues
+provides the durability and the rates of the consumed queues, consumers of a queue missing from it skip
+the alerts needing them. th may be nil for the default thresholds
+*/
+func EvaluateConsumers(consumers []rabbithole.ConsumerInfo, queues []rabbithole.QueueInfo, th *Thresholds) []ConsumerProperties {
+	byKey := make(map[queueKey]*rabbithole.QueueInfo, len(queues))
+	for i := range queues {
+		byKey[queueKey{queues[i].Vhost, queues[i].Name}] = &queues[i]
+	}
+
+	var mapConsumers []ConsumerProperties
+	for _, consumer := range consumers {
+		cp := &ConsumerProperties{
+			ConsumerInfo: consumer,
+			QueueInfo:    byKey[queueKey{consumer.Queue.Vhost, consumer.Queue.Name}],
+			Thresholds:   th.consumer(),
+		}
+		cp.Calculate()
+		mapConsumers = append(mapConsumers, *cp)
+	}
+
+	sort.SliceStable(mapConsumers, func(i, j int) bool {
+		return mapConsumers[i].Level() > mapConsumers[j].Level()
+	})
+
+	return mapConsumers
+}
+
+/*
+QueueConsumers is the consumer health report of a queue
+*/
+type QueueConsumers struct {
+	Vhost     string
+	Queue     string
+	Consumers []ConsumerProperties // worst first
+}
+
+/*
+Level returns the worst level of the consumers of the queue
+*/
+func (qc *QueueConsumers) Level() Level {
+	worst := LevelOK
+	for i := range qc.Consumers {
+		if l := qc.Consumers[i].Level(); l > worst {
+			worst = l
+		}
+	}
+	return worst
+}
+
+/*
+GroupConsumers builds the report of every consumed queue, the queues with the worst consumers first and
+then by vhost and name
+*/
+func GroupConsumers(consumers []ConsumerProperties) []QueueConsumers {
+	index := make(map[queueKey]int)
+	var report []QueueConsumers
+	for _, cp := range consumers {
+		key := queueKey{cp.ConsumerInfo.Queue.Vhost, cp.ConsumerInfo.Queue.Name}
+		i, ok := index[key]
+		if !ok {
+			i = len(report)
+			index[key] = i
+			report = append(report, QueueConsumers{Vhost: key.vhost, Queue: key.name})
+		}
+		report[i].Consumers = append(report[i].Consumers, cp)
+	}
+
+	for i := range report {
+		consumers := report[i].Consumers
+		sort.SliceStable(consumers, func(a, b int) bool {
+			return consumers[a].Level() > consumers[b].Level()
+		})
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		first, second := &report[i], &report[j]
+		if first.Level() != second.Level() {
+			return first.Level() > second.Level()
+		}
+		if first.Vhost != second.Vhost {
+			return first.Vhost < second.Vhost
+		}
+		return first.Queue < second.Queue
+	})
+
+	return report
+}
@@ -0,0 +1,288 @@
+package rabbitmonit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Credentials is a login/password pair for the management api. it never prints the password
+*/
+type Credentials struct {
+	Login    string
+	Password string
+}
+
+/*
+String implements fmt.Stringer hiding the password
+*/
+func (c Credentials) String() string {
+	return c.Login + ":***"
+}
+
+/*
+GoString implements fmt.GoStringer hiding the password
+*/
+func (c Credentials) GoString() string {
+	return fmt.Sprintf("rabbitmonit.Credentials{Login: %q, Password: \"***\"}", c.Login)
+}
+
+/*
+CredentialProvider supplies the credentials used for the management api. Ops asks for them before every
+request so rotated credentials are picked up without restarting. implementations must be safe for concurrent use
+*/
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+/*
+StaticCredentials always returns the same credentials
+*/
+type StaticCredentials Credentials
+
+/*
+Credentials implements CredentialProvider
+*/
+func (s StaticCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	return Credentials(s), nil
+}
+
+/*
+EnvCredentials reads the credentials from environment variables on every call
+*/
+type EnvCredentials struct {
+	LoginVar    string // defaults to RABBITMONIT_LOGIN
+	PasswordVar string // defaults to RABBITMONIT_PASSWORD
+}
+
+/*
+Credentials implements CredentialProvider
+*/
+func (e EnvCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	loginVar, passwordVar := e.LoginVar, e.PasswordVar
+	if loginVar == "" {
+		loginVar = "RABBITMONIT_LOGIN"
+	}
+	if passwordVar == "" {
+		passwordVar = "RABBITMONIT_PASSWORD"
+	}
+
+	password, ok := os.LookupEnv(passwordVar)
+	if !ok {
+		return Credentials{}, fmt.Errorf("rabbitmonit: %s is not set", passwordVar)
+	}
+	return Credentials{Login: os.Getenv(loginVar), Password: password}, nil
+}
+
+/*
+FileCredentials reads the password (and optionally the login) from files, typically mounted secrets.
+the files are read again whenever their modification time changes
+*/
+type FileCredentials struct {
+	Login        string // used when LoginFile is empty
+	LoginFile    string
+	PasswordFile string
+
+	mu     sync.Mutex
+	cached Credentials
+	stamps [2]time.Time
+}
+
+/*
+Credentials implements CredentialProvider
+*/
+func (f *FileCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	passwordStamp, err := modTime(f.PasswordFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var loginStamp time.Time
+	if f.LoginFile != "" {
+		if loginStamp, err = modTime(f.LoginFile); err != nil {
+			return Credentials{}, err
+		}
+	}
+
+	stamps := [2]time.Time{loginStamp, passwordStamp}
+	if stamps == f.stamps && f.cached.Password != "" {
+		return f.cached, nil
+	}
+
+	creds := Credentials{Login: f.Login}
+	if creds.Password, err = readTrimmed(f.PasswordFile); err != nil {
+		return Credentials{}, err
+	}
+	if f.LoginFile != "" {
+		if creds.Login, err = readTrimmed(f.LoginFile); err != nil {
+			return Credentials{}, err
+		}
+	}
+
+	f.cached, f.stamps = creds, stamps
+	return creds, nil
+}
+
+func modTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func readTrimmed(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+/*
+NetrcCredentials looks up the credentials of Machine in a netrc file, read again on every call
+*/
+type NetrcCredentials struct {
+	Path    string // defaults to ~/.netrc
+	Machine string // the host name of the management api
+}
+
+/*
+Credentials implements CredentialProvider
+*/
+func (n NetrcCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	path := n.Path
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Credentials{}, err
+		}
+		path = home + "/.netrc"
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	creds, ok := parseNetrc(data, n.Machine)
+	if !ok {
+		return Credentials{}, fmt.Errorf("rabbitmonit: no entry for %s in %s", n.Machine, path)
+	}
+	return creds, nil
+}
+
+/*
+parseNetrc returns the login/password of machine, falling back to the default entry
+*/
+func parseNetrc(data []byte, machine string) (Credentials, bool) {
+	var found, fallback *Credentials
+	var current *Credentials
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			current = nil
+			if scanner.Scan() && scanner.Text() == machine && found == nil {
+				found = &Credentials{}
+				current = found
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &Credentials{}
+				current = fallback
+			}
+		case "login":
+			if scanner.Scan() && current != nil {
+				current.Login = scanner.Text()
+			}
+		case "password":
+			if scanner.Scan() && current != nil {
+				current.Password = scanner.Text()
+			}
+		case "macdef":
+			// macro definitions are not supported, their content is not attributed to any entry
+			current = nil
+		}
+	}
+
+	if found != nil {
+		return *found, true
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return Credentials{}, false
+}
+
+/*
+ExecCredentials runs a helper command printing {"login": "...", "password": "..."} on stdout.
+the answer is cached for TTL, 0 runs the command before every request
+*/
+type ExecCredentials struct {
+	Command []string
+	TTL     time.Duration
+
+	mu      sync.Mutex
+	cached  Credentials
+	expires time.Time
+}
+
+/*
+Credentials implements CredentialProvider
+*/
+func (e *ExecCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	if len(e.Command) == 0 {
+		return Credentials{}, errors.New("rabbitmonit: no credentials command configured")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if time.Now().Before(e.expires) {
+		return e.cached, nil
+	}
+
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		// the output of the helper is never part of the error, it may contain the secret
+		return Credentials{}, fmt.Errorf("rabbitmonit: credentials command %s failed: %v", e.Command[0], err)
+	}
+
+	var answer struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal(out, &answer); err != nil {
+		return Credentials{}, fmt.Errorf("rabbitmonit: credentials command %s printed invalid json", e.Command[0])
+	}
+
+	e.cached = Credentials{Login: answer.Login, Password: answer.Password}
+	e.expires = time.Now().Add(e.TTL)
+	return e.cached, nil
+}
+
+/*
+credentials returns the credentials to use for the next request
+*/
+func (p *Ops) credentials(ctx context.Context) (Credentials, error) {
+	if p.Credentials == nil {
+		return Credentials{Login: p.Login, Password: p.Password}, nil
+	}
+	return p.Credentials.Credentials(ctx)
+}
@@ -0,0 +1,70 @@
+package rabbitmonit
+
+import "testing"
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		name    string
+		netrc   string
+		machine string
+		want    Credentials
+		found   bool
+	}{
+		{
+			name:    "machine",
+			netrc:   "machine rabbit.local login monitor password secret",
+			machine: "rabbit.local",
+			want:    Credentials{Login: "monitor", Password: "secret"},
+			found:   true,
+		},
+		{
+			name:    "other machine",
+			netrc:   "machine other.local login monitor password secret",
+			machine: "rabbit.local",
+		},
+		{
+			name:    "machine wins over an earlier default",
+			netrc:   "default login anonymous password none\nmachine rabbit.local login monitor password secret",
+			machine: "rabbit.local",
+			want:    Credentials{Login: "monitor", Password: "secret"},
+			found:   true,
+		},
+		{
+			name:    "default as fallback",
+			netrc:   "machine other.local login other password other\ndefault login anonymous password none",
+			machine: "rabbit.local",
+			want:    Credentials{Login: "anonymous", Password: "none"},
+			found:   true,
+		},
+		{
+			name:    "first matching machine",
+			netrc:   "machine rabbit.local login first password one\nmachine rabbit.local login second password two",
+			machine: "rabbit.local",
+			want:    Credentials{Login: "first", Password: "one"},
+			found:   true,
+		},
+		{
+			name:    "macdef is not attributed to the entry",
+			netrc:   "machine rabbit.local login monitor password secret macdef init\nlogin macro password macro\n\n",
+			machine: "rabbit.local",
+			want:    Credentials{Login: "monitor", Password: "secret"},
+			found:   true,
+		},
+		{
+			name:    "multi line entry",
+			netrc:   "machine rabbit.local\n\tlogin monitor\n\tpassword secret\n",
+			machine: "rabbit.local",
+			want:    Credentials{Login: "monitor", Password: "secret"},
+			found:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := parseNetrc([]byte(tt.netrc), tt.machine)
+			if found != tt.found || got != tt.want {
+				t.Errorf("parseNetrc = %#v, %v, want %#v, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
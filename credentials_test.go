@@ -0,0 +1,139 @@
+package rabbitmonit_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+/*
+TestCredentialProvider checks that every request, the consumers lookup of CalculateContext included,
+authenticates with the provider rather than Login and Password
+*/
+func TestCredentialProvider(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{
+		Queues: []rabbithole.QueueInfo{
+			{Name: "orders", Vhost: "/", State: "running", Durable: true, MessagesUnack: 50},
+		},
+		Consumers: []rabbithole.ConsumerInfo{
+			{ConsumerTag: "c1", PrefetchCount: 10, Queue: rabbithole.QueueDetail{Name: "orders", Vhost: "/"}},
+		},
+	})
+	defer srv.Close()
+
+	ops := &rabbitmonit.Ops{
+		Host:        srv.URL,
+		Login:       "stale",
+		Password:    "stale",
+		Credentials: rabbitmonit.StaticCredentials{Login: fakeapi.Login, Password: fakeapi.Password},
+	}
+	queues, err := ops.ListAccumulationQueues()
+	if err != nil {
+		t.Fatalf("ListAccumulationQueues: %v", err)
+	}
+
+	qp := queues[0]
+	qp.Consumers = nil
+	qp.CalculateContext(context.Background())
+	if !qp.Error.Unack {
+		t.Error("unack not raised, the consumers lookup did not use the provider")
+	}
+}
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir := t.TempDir()
+	loginFile, passwordFile := filepath.Join(dir, "login"), filepath.Join(dir, "password")
+	start := time.Now().Add(-time.Hour)
+	writeFile(t, loginFile, "monitor\n", start)
+	writeFile(t, passwordFile, "first\n", start)
+
+	f := &rabbitmonit.FileCredentials{LoginFile: loginFile, PasswordFile: passwordFile}
+	steps := []struct {
+		name    string
+		update  func()
+		want    rabbitmonit.Credentials
+		wantErr bool
+	}{
+		{"initial read", func() {}, rabbitmonit.Credentials{Login: "monitor", Password: "first"}, false},
+		{"same mtime is cached", func() { writeFile(t, passwordFile, "unseen\n", start) }, rabbitmonit.Credentials{Login: "monitor", Password: "first"}, false},
+		{"password rotated", func() { writeFile(t, passwordFile, "second\n", start.Add(time.Minute)) }, rabbitmonit.Credentials{Login: "monitor", Password: "second"}, false},
+		{"login rotated", func() { writeFile(t, loginFile, "auditor\r\n", start.Add(time.Minute)) }, rabbitmonit.Credentials{Login: "auditor", Password: "second"}, false},
+		{"password removed", func() { os.Remove(passwordFile) }, rabbitmonit.Credentials{}, true},
+	}
+
+	for _, step := range steps {
+		step.update()
+		got, err := f.Credentials(context.Background())
+		if (err != nil) != step.wantErr || got != step.want {
+			t.Errorf("%s: Credentials = %#v, %v, want %#v (error %v)", step.name, got, err, step.want, step.wantErr)
+		}
+	}
+}
+
+func TestExecCredentials(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh available")
+	}
+	const secret = "s3cr3t-value"
+
+	tests := []struct {
+		name    string
+		script  string
+		ttl     time.Duration
+		calls   int
+		runs    int
+		want    rabbitmonit.Credentials
+		wantErr bool
+	}{
+		{"cached for ttl", `echo '{"login": "monitor", "password": "` + secret + `"}'`, time.Hour, 3, 1, rabbitmonit.Credentials{Login: "monitor", Password: secret}, false},
+		{"no ttl runs every time", `echo '{"login": "monitor", "password": "` + secret + `"}'`, 0, 3, 3, rabbitmonit.Credentials{Login: "monitor", Password: secret}, false},
+		{"failure hides the output", `echo '{"password": "` + secret + `"}'; echo ` + secret + ` >&2; exit 1`, time.Hour, 2, 2, rabbitmonit.Credentials{}, true},
+		{"invalid json hides the output", `echo 'password=` + secret + `'`, time.Hour, 2, 2, rabbitmonit.Credentials{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := filepath.Join(t.TempDir(), "runs")
+			e := &rabbitmonit.ExecCredentials{
+				Command: []string{"sh", "-c", "echo run >> " + counter + "; " + tt.script},
+				TTL:     tt.ttl,
+			}
+
+			for i := 0; i < tt.calls; i++ {
+				got, err := e.Credentials(context.Background())
+				if (err != nil) != tt.wantErr || got != tt.want {
+					t.Fatalf("call %d: Credentials = %#v, %v, want %#v (error %v)", i, got, err, tt.want, tt.wantErr)
+				}
+				if err != nil && strings.Contains(err.Error(), secret) {
+					t.Errorf("call %d: error %q leaks the helper output", i, err)
+				}
+			}
+
+			data, err := os.ReadFile(counter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if runs := strings.Count(string(data), "run"); runs != tt.runs {
+				t.Errorf("the helper ran %d times, want %d", runs, tt.runs)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package rabbitmonit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+var (
+	// ErrUnauthorized is reported when the management api rejects the configured credentials
+	ErrUnauthorized = errors.New("rabbitmonit: unauthorized")
+	// ErrNotFound is reported when the requested vhost/queue/node does not exist
+	ErrNotFound = errors.New("rabbitmonit: not found")
+	// ErrTimeout is reported when the management api did not answer in time
+	ErrTimeout = errors.New("rabbitmonit: timeout")
+	// ErrMalformed is reported when the management api answered with something we cannot decode
+	ErrMalformed = errors.New("rabbitmonit: malformed response")
+)
+
+/*
+APIError is returned by all the Ops methods when a call against the management api fails.
+
+Kind holds one of ErrUnauthorized, ErrNotFound, ErrTimeout or ErrMalformed (or nil when the failure
+does not fit any of them) so callers can use errors.Is to decide how to degrade
+*/
+type APIError struct {
+	Op         string // the Ops operation that failed, eg. "ListQueues"
+	Path       string // the management api path that was requested
+	StatusCode int    // the http status code, 0 if no response was received
+	Kind       error  // the classification of the error
+	Err        error  // the underlying error
+}
+
+/*
+Error implements the error interface
+*/
+func (e *APIError) Error() string {
+	msg := "rabbitmonit: " + e.Op
+	if e.Path != "" {
+		msg += " " + e.Path
+	}
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+/*
+Unwrap exposes both the classification and the underlying error to errors.Is/errors.As
+*/
+func (e *APIError) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+/*
+kindFromStatus maps an http status code to one of the error classifications
+*/
+func kindFromStatus(status int) error {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ErrTimeout
+	}
+	return nil
+}
+
+/*
+kindFromError tries to classify a transport or decoding error
+*/
+func kindFromError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrMalformed
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package rabbitmonit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+func TestAPIErrorKinds(t *testing.T) {
+	listings := []struct {
+		name string
+		path string
+		list func(ctx context.Context, ops *rabbitmonit.Ops) error
+	}{
+		{"ListNodes", "/api/nodes", func(ctx context.Context, ops *rabbitmonit.Ops) error {
+			_, err := ops.ListNodesContext(ctx)
+			return err
+		}},
+		{"ListVhosts", "/api/vhosts", func(ctx context.Context, ops *rabbitmonit.Ops) error {
+			_, err := ops.ListVhostsContext(ctx)
+			return err
+		}},
+		{"ListQueues", "/api/queues/%2F", func(ctx context.Context, ops *rabbitmonit.Ops) error {
+			_, err := ops.ListQueuesContext(ctx, "/")
+			return err
+		}},
+		{"ListAccumulationQueues", "/api/queues", func(ctx context.Context, ops *rabbitmonit.Ops) error {
+			_, err := ops.ListAccumulationQueuesContext(ctx)
+			return err
+		}},
+		{"ListExchanges", "/api/exchanges", func(ctx context.Context, ops *rabbitmonit.Ops) error {
+			_, err := ops.ListExchangesContext(ctx, "")
+			return err
+		}},
+		{"ListConnections", "/api/connections", func(ctx context.Context, ops *rabbitmonit.Ops) error {
+			_, err := ops.ListConnectionsContext(ctx, "")
+			return err
+		}},
+		{"ListChannels", "/api/channels", func(ctx context.Context, ops *rabbitmonit.Ops) error {
+			_, err := ops.ListChannelsContext(ctx, "")
+			return err
+		}},
+	}
+
+	tests := []struct {
+		name       string
+		failure    fakeapi.Failure
+		timeout    time.Duration // deadline of the call, none when 0
+		wantKind   error
+		wantStatus int
+	}{
+		{"unauthorized", fakeapi.Failure{Status: http.StatusUnauthorized}, 0, rabbitmonit.ErrUnauthorized, http.StatusUnauthorized},
+		{"forbidden", fakeapi.Failure{Status: http.StatusForbidden}, 0, rabbitmonit.ErrUnauthorized, http.StatusForbidden},
+		{"not found", fakeapi.Failure{Status: http.StatusNotFound}, 0, rabbitmonit.ErrNotFound, http.StatusNotFound},
+		{"deadline", fakeapi.Failure{Delay: time.Second}, 20 * time.Millisecond, rabbitmonit.ErrTimeout, 0},
+		{"gateway timeout", fakeapi.Failure{Status: http.StatusGatewayTimeout}, 0, rabbitmonit.ErrTimeout, http.StatusGatewayTimeout},
+		{"invalid json", fakeapi.Failure{Status: http.StatusOK, Body: "{"}, 0, rabbitmonit.ErrMalformed, http.StatusOK},
+		{"unexpected json", fakeapi.Failure{Status: http.StatusOK, Body: `{"name": "rabbit@a"}`}, 0, rabbitmonit.ErrMalformed, http.StatusOK},
+		{"server error", fakeapi.Failure{Status: http.StatusInternalServerError}, 0, nil, http.StatusInternalServerError},
+	}
+
+	srv := fakeapi.NewServer(fakeapi.Fixtures{})
+	defer srv.Close()
+
+	for _, listing := range listings {
+		for _, tt := range tests {
+			t.Run(listing.name+"/"+tt.name, func(t *testing.T) {
+				srv.Fail(listing.path, tt.failure)
+				defer srv.Recover(listing.path)
+
+				ctx := context.Background()
+				if tt.timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+					defer cancel()
+				}
+
+				err := listing.list(ctx, srv.Ops())
+				var apiErr *rabbitmonit.APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("err = %v (%T), want an *APIError", err, err)
+				}
+				if apiErr.Kind != tt.wantKind || (tt.wantKind != nil && !errors.Is(err, tt.wantKind)) {
+					t.Errorf("kind = %v, want %v", apiErr.Kind, tt.wantKind)
+				}
+				if apiErr.StatusCode != tt.wantStatus {
+					t.Errorf("status = %d, want %d", apiErr.StatusCode, tt.wantStatus)
+				}
+				if apiErr.Op != listing.name {
+					t.Errorf("op = %q, want %q", apiErr.Op, listing.name)
+				}
+			})
+		}
+	}
+}
+
+func TestAPIErrorClientTimeout(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{})
+	defer srv.Close()
+	srv.Fail("/api/nodes", fakeapi.Failure{Delay: time.Second})
+
+	// the timeout of the http client is a net.Error rather than a context deadline
+	ops := srv.Ops()
+	ops.Timeout = 20 * time.Millisecond
+	if _, err := ops.ListNodes(); !errors.Is(err, rabbitmonit.ErrTimeout) {
+		t.Errorf("err = %v, want ErrTimeout", err)
+	}
+}
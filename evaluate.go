@@ -0,0 +1,159 @@
+package rabbitmonit
+
+import (
+	"sort"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+EvaluateNodes calculates the properties of the given nodes, th may be nil for the default thresholds
+*/
+func EvaluateNodes(nodes []rabbithole.NodeInfo, th *Thresholds) []NodeProperties {
+	var returnNodes []NodeProperties
+	for _, node := range nodes {
+		localNode := NodeProperties{
+			NodeInfo:   node,
+			Thresholds: th.node(),
+		}
+
+		localNode.Calculate()
+
+		returnNodes = append(returnNodes, localNode)
+	}
+
+	return returnNodes
+}
+
+/*
+EvaluateVhosts calculates the properties of the given vhosts and sorts them by warnings/errors,
+th may be nil for the default thresholds
+*/
+func EvaluateVhosts(vhosts []rabbithole.VhostInfo, th *Thresholds) []VhostProperties {
+	var mapVhosts []VhostProperties
+
+	for _, vhost := range vhosts {
+		vh := &VhostProperties{
+			VhostInfo:  vhost,
+			Thresholds: th.vhost(),
+		}
+		vh.Calculate()
+		mapVhosts = append(mapVhosts, *vh)
+	}
+
+	vs := &vhostSorter{}
+	vs.Sort(mapVhosts)
+
+	return mapVhosts
+}
+
+/*
+EvaluateQueues calculates the properties of the given queues and sorts them by warnings/errors.
+
+all the queues share the consumers index, so no api call is made. without an index the unacked
+messages alert is skipped. th may be nil for the default thresholds
+*/
+func EvaluateQueues(queues []rabbithole.QueueInfo, consumers *ConsumerIndex, th *Thresholds) []QueueProperties {
+	var mapExtendedQueues []QueueProperties
+
+	for _, q := range queues {
+		extQueue := new(QueueProperties)
+		extQueue.QueueInfo = q
+		extQueue.Thresholds = th.queue()
+		extQueue.Overrides = th.queueOverrides()
+		extQueue.Consumers = consumers
+		extQueue.Calculate()
+
+		mapExtendedQueues = append(mapExtendedQueues, *extQueue)
+	}
+
+	qs := &queueSorter{}
+	qs.Sort(mapExtendedQueues)
+
+	return mapExtendedQueues
+}
+
+/*
+EvaluateExchanges calculates the properties of the given exchanges and sorts them worst first, the
+highest unroutable rates leading within a level.
+
+bindings may cover more exchanges than the given ones, each exchange only keeps the bindings it is the
+source of. th may be nil for the default thresholds
+*/
+func EvaluateExchanges(exchanges []ExchangeInfo, bindings []rabbithole.BindingInfo, th *Thresholds) []ExchangeProperties {
+	bySource := make(map[[2]string][]rabbithole.BindingInfo)
+	for _, binding := range bindings {
+		source := [2]string{binding.Vhost, binding.Source}
+		bySource[source] = append(bySource[source], binding)
+	}
+
+	var mapExchanges []ExchangeProperties
+	for _, exchange := range exchanges {
+		ep := &ExchangeProperties{
+			ExchangeInfo: exchange,
+			Bindings:     bySource[[2]string{exchange.Vhost, exchange.Name}],
+			Thresholds:   th.exchange(),
+		}
+		ep.Calculate()
+		mapExchanges = append(mapExchanges, *ep)
+	}
+
+	sort.SliceStable(mapExchanges, func(i, j int) bool {
+		first, second := &mapExchanges[i], &mapExchanges[j]
+		if first.Level() != second.Level() {
+			return first.Level() > second.Level()
+		}
+		return first.Stats.UnroutableRate > second.Stats.UnroutableRate
+	})
+
+	return mapExchanges
+}
+
+/*
+EvaluateConnections calculates the properties of the given connections and sorts them worst first, the
+connections with the most channels leading within a level. ages are measured up to now, th may be nil
+for the default thresholds
+*/
+func EvaluateConnections(connections []ConnectionInfo, now time.Time, th *Thresholds) []ConnectionProperties {
+	var mapConnections []ConnectionProperties
+	for _, connection := range connections {
+		cp := &ConnectionProperties{
+			ConnectionInfo: connection,
+			Now:            now,
+			Thresholds:     th.connection(),
+		}
+		cp.Calculate()
+		mapConnections = append(mapConnections, *cp)
+	}
+
+	sort.SliceStable(mapConnections, func(i, j int) bool {
+		first, second := &mapConnections[i], &mapConnections[j]
+		if first.Level() != second.Level() {
+			return first.Level() > second.Level()
+		}
+		return first.Stats.Channels > second.Stats.Channels
+	})
+
+	return mapConnections
+}
+
+/*
+EvaluateChannels calculates the properties of the given channels for a single poll and sorts them worst
+first. the saturated alert needs several polls, see ChannelTracker. th may be nil for the default
+thresholds
+*/
+func EvaluateChannels(channels []ChannelInfo, th *Thresholds) []ChannelProperties {
+	var mapChannels []ChannelProperties
+	for _, channel := range channels {
+		cp := &ChannelProperties{
+			ChannelInfo: channel,
+			Thresholds:  th.channel(),
+		}
+		cp.Calculate()
+		mapChannels = append(mapChannels, *cp)
+	}
+
+	sortChannels(mapChannels)
+	return mapChannels
+}
@@ -0,0 +1,139 @@
+package rabbitmonit
+
+import (
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+ExchangeProperties extends ExchangeInfo with additional alerting/status values
+*/
+type ExchangeProperties struct {
+	Stats        ExchangeStats
+	Error        ExchangeAlert
+	Warning      ExchangeAlert
+	ExchangeInfo ExchangeInfo
+	Bindings     []rabbithole.BindingInfo // bindings having the exchange as source
+	Cluster      string                   // name of the cluster the exchange belongs to, set by Fleet
+	Thresholds   *ExchangeThresholds      // the limits used for alerting, nil means DefaultExchangeThresholds
+}
+
+/*
+ExchangeStats holds the statistics calculated for an exchange
+*/
+type ExchangeStats struct {
+	Bindings       int     // number of bindings having the exchange as source
+	PublishInRate  float64 // messages published to the exchange per second
+	UnroutableRate float64 // messages returned or dropped as unroutable per second
+}
+
+/*
+ExchangeAlert contains all the alerts that an exchange can report
+*/
+type ExchangeAlert struct {
+	Has        bool // do we have any errors
+	NoBindings bool // messages are published but nothing is bound to the exchange
+	Unroutable bool // messages are returned to the publisher or dropped because no binding matched
+	NonDurable bool // the exchange does not survive a broker restart
+}
+
+/*
+Kinds returns the names of the flags that are set, Has excluded
+*/
+func (ea ExchangeAlert) Kinds() []string {
+	var kinds []string
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{ea.NoBindings, "no_bindings"},
+		{ea.Unroutable, "unroutable"},
+		{ea.NonDurable, "non_durable"},
+	} {
+		if flag.set {
+			kinds = append(kinds, flag.name)
+		}
+	}
+	return kinds
+}
+
+/*
+Calculate runs all the statistics on the current exchange
+*/
+func (ep *ExchangeProperties) Calculate() {
+	ep.Error = ExchangeAlert{}
+	ep.Warning = ExchangeAlert{}
+	ep.Stats = ExchangeStats{}
+
+	ep.calculateStats().
+		alertNoBindings().
+		alertUnroutable().
+		alertDurable()
+}
+
+/*
+thresholds returns the limits to be used for the current exchange
+*/
+func (ep *ExchangeProperties) thresholds() ExchangeThresholds {
+	if ep.Thresholds != nil {
+		return *ep.Thresholds
+	}
+	return DefaultExchangeThresholds()
+}
+
+/*
+calculateStats fills in the binding count and the rates
+*/
+func (ep *ExchangeProperties) calculateStats() *ExchangeProperties {
+	stats := ep.ExchangeInfo.MessageStats
+	ep.Stats.Bindings = len(ep.Bindings)
+	ep.Stats.PublishInRate = stats.PublishInDetails.Rate
+	ep.Stats.UnroutableRate = stats.ReturnUnroutableDetails.Rate + stats.DropUnroutableDetails.Rate
+	return ep
+}
+
+/*
+alertNoBindings raises an error when messages are published to an exchange without bindings, they are
+all lost. the default exchange routes to every queue implicitly and exchanges with an alternate exchange
+hand the messages over, both are skipped
+*/
+func (ep *ExchangeProperties) alertNoBindings() *ExchangeProperties {
+	if ep.ExchangeInfo.Name == "" || ep.ExchangeInfo.Arguments["alternate-exchange"] != nil {
+		return ep
+	}
+
+	if ep.Stats.PublishInRate > 0 && ep.Stats.Bindings == 0 {
+		ep.Error.Has = true
+		ep.Error.NoBindings = true
+	}
+	return ep
+}
+
+/*
+alertUnroutable raises an alert/warning when messages are returned or dropped as unroutable
+
+threshold for alert is UnroutableError (default 1/s)
+
+threshold for warning is UnroutableWarning (default 0/s)
+*/
+func (ep *ExchangeProperties) alertUnroutable() *ExchangeProperties {
+	th := ep.thresholds()
+	if ep.Stats.UnroutableRate > th.UnroutableError {
+		ep.Error.Has = true
+		ep.Error.Unroutable = true
+	} else if ep.Stats.UnroutableRate > th.UnroutableWarning {
+		ep.Warning.Has = true
+		ep.Warning.Unroutable = true
+	}
+	return ep
+}
+
+/*
+alertDurable raises an error when the exchange is not durable, it disappears with its bindings on restart
+*/
+func (ep *ExchangeProperties) alertDurable() *ExchangeProperties {
+	if !ep.ExchangeInfo.Durable {
+		ep.Error.Has = true
+		ep.Error.NonDurable = true
+	}
+	return ep
+}
@@ -0,0 +1,212 @@
+/*
+Package exporter exposes the rabbit-monit statistics and alerts as prometheus metrics
+*/
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rabbitmonit"
+
+var (
+	upDesc = prometheus.NewDesc(namespace+"_up",
+		"Whether the last collection of the given part succeeded.", []string{"part"}, nil)
+	alertDesc = prometheus.NewDesc(namespace+"_alert",
+		"Alert flags currently raised, one series per entity, level and kind.",
+		[]string{"entity", "vhost", "queue", "node", "level", "kind"}, nil)
+
+	queueLabels = []string{"vhost", "queue"}
+	queueDescs  = map[string]*prometheus.Desc{
+		"ready":                queueDesc("messages_ready", "Messages ready for delivery."),
+		"unacked":              queueDesc("messages_unacknowledged", "Messages delivered but not yet acknowledged."),
+		"non_persistent":       queueDesc("messages_non_persistent", "Messages that will not survive a restart."),
+		"consumers":            queueDesc("consumers", "Number of consumers."),
+		"utilisation":          queueDesc("consumer_utilisation", "Consumer utilisation."),
+		"enqueue_dequeue_diff": queueDesc("enqueue_dequeue_diff", "Difference between the publish and deliver rates."),
+		"messages_ready_rate":  queueDesc("messages_ready_rate", "Growth rate of the ready messages."),
+		"messages_persistent":  queueDesc("messages_persistent", "Persistent messages."),
+		"messages":             queueDesc("messages", "Total messages."),
+	}
+
+	vhostLabels = []string{"vhost"}
+	vhostDescs  = map[string]*prometheus.Desc{
+		"ready":                vhostDesc("messages_ready", "Messages ready for delivery."),
+		"unacked":              vhostDesc("messages_unacknowledged", "Messages delivered but not yet acknowledged."),
+		"enqueue_dequeue_diff": vhostDesc("enqueue_dequeue_diff", "Difference between the publish and deliver rates."),
+	}
+
+	nodeLabels = []string{"node"}
+	nodeDescs  = map[string]*prometheus.Desc{
+		"fd":      nodeDesc("fd_used_percentage", "Percentage of used file descriptors."),
+		"disk":    nodeDesc("disk_used_percentage", "Percentage of disk used until the alarm limit."),
+		"mem":     nodeDesc("mem_used_percentage", "Percentage of memory used until the alarm limit."),
+		"erl":     nodeDesc("erl_used_percentage", "Percentage of erlang processes used."),
+		"sock":    nodeDesc("sock_used_percentage", "Percentage of sockets used."),
+		"running": nodeDesc("running", "Whether the node is running."),
+	}
+)
+
+func queueDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(namespace+"_queue_"+name, help, queueLabels, nil)
+}
+
+func vhostDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(namespace+"_vhost_"+name, help, vhostLabels, nil)
+}
+
+func nodeDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(namespace+"_node_"+name, help, nodeLabels, nil)
+}
+
+/*
+Collector is a prometheus.Collector polling the management api through Ops on every scrape.
+
+only the raised alert flags are exported, a resolved alert disappears from rabbitmonit_alert
+*/
+type Collector struct {
+	Ops     *rabbitmonit.Ops
+	Timeout time.Duration // upper bound for a full collection, 0 means no limit
+}
+
+/*
+NewCollector returns a collector for the cluster behind ops
+*/
+func NewCollector(ops *rabbitmonit.Ops) *Collector {
+	return &Collector{Ops: ops}
+}
+
+/*
+Handler returns an http.Handler serving the metrics of the cluster behind ops, meant to be mounted on /metrics
+*/
+func Handler(ops *rabbitmonit.Ops) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewCollector(ops))
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+/*
+Describe implements prometheus.Collector
+*/
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- alertDesc
+	for _, descs := range []map[string]*prometheus.Desc{queueDescs, vhostDescs, nodeDescs} {
+		for _, desc := range descs {
+			ch <- desc
+		}
+	}
+}
+
+/*
+Collect implements prometheus.Collector
+*/
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	nodes, err := c.Ops.ListNodesContext(ctx)
+	ch <- up("nodes", err)
+	for _, node := range nodes {
+		CollectNode(ch, node)
+	}
+
+	vhosts, err := c.Ops.ListVhostsContext(ctx)
+	ch <- up("vhosts", err)
+	for _, vhost := range vhosts {
+		CollectVhost(ch, vhost)
+	}
+
+	queues, err := c.Ops.ListAccumulationQueuesContext(ctx)
+	ch <- up("queues", err)
+	for _, queue := range queues {
+		CollectQueue(ch, queue)
+	}
+}
+
+/*
+CollectQueue sends the stats and raised alerts of a queue to ch
+*/
+func CollectQueue(ch chan<- prometheus.Metric, qp rabbitmonit.QueueProperties) {
+	info := qp.QueueInfo
+	gauge := func(key string, value float64) {
+		ch <- prometheus.MustNewConstMetric(queueDescs[key], prometheus.GaugeValue, value, info.Vhost, info.Name)
+	}
+
+	gauge("ready", float64(info.MessagesRdy))
+	gauge("unacked", float64(info.MessagesUnack))
+	gauge("non_persistent", float64(qp.Stats.NonPersistentMessagesCount))
+	gauge("consumers", float64(info.Consumers))
+	gauge("utilisation", qp.Stats.Utilisation)
+	gauge("enqueue_dequeue_diff", float64(qp.Stats.EnqueueDequeueDiff))
+	gauge("messages_ready_rate", float64(info.MessagesRdyDetails.Rate))
+	gauge("messages_persistent", float64(info.MessagesPersistent))
+	gauge("messages", float64(info.Messages))
+
+	alerts(ch, "queue", info.Vhost, info.Name, "", "warning", qp.Warning.Kinds())
+	alerts(ch, "queue", info.Vhost, info.Name, "", "error", qp.Error.Kinds())
+}
+
+/*
+CollectVhost sends the stats and raised alerts of a vhost to ch
+*/
+func CollectVhost(ch chan<- prometheus.Metric, vp rabbitmonit.VhostProperties) {
+	info := vp.VhostInfo
+	gauge := func(key string, value float64) {
+		ch <- prometheus.MustNewConstMetric(vhostDescs[key], prometheus.GaugeValue, value, info.Name)
+	}
+
+	gauge("ready", float64(info.MessagesRdy))
+	gauge("unacked", float64(info.MessagesUnack))
+	gauge("enqueue_dequeue_diff", float64(vp.Stats.EnqueueDequeueDiff))
+
+	alerts(ch, "vhost", info.Name, "", "", "warning", vp.Warning.Kinds())
+	alerts(ch, "vhost", info.Name, "", "", "error", vp.Error.Kinds())
+}
+
+/*
+CollectNode sends the stats and raised alerts of a node to ch
+*/
+func CollectNode(ch chan<- prometheus.Metric, np rabbitmonit.NodeProperties) {
+	info := np.NodeInfo
+	gauge := func(key string, value float64) {
+		ch <- prometheus.MustNewConstMetric(nodeDescs[key], prometheus.GaugeValue, value, info.Name)
+	}
+
+	gauge("fd", np.Stats.FdUsedPercentage)
+	gauge("disk", np.Stats.DiskUsedPercentage)
+	gauge("mem", np.Stats.MemUsedPercentage)
+	gauge("erl", np.Stats.ErlUsedPercentage)
+	gauge("sock", np.Stats.SockUsedPercentage)
+	gauge("running", boolValue(info.Running))
+
+	alerts(ch, "node", "", "", info.Name, "warning", np.Warning.Kinds())
+	alerts(ch, "node", "", "", info.Name, "error", np.Error.Kinds())
+}
+
+func alerts(ch chan<- prometheus.Metric, entity, vhost, queue, node, level string, kinds []string) {
+	for _, kind := range kinds {
+		ch <- prometheus.MustNewConstMetric(alertDesc, prometheus.GaugeValue, 1, entity, vhost, queue, node, level, kind)
+	}
+}
+
+func up(part string, err error) prometheus.Metric {
+	return prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, boolValue(err == nil), part)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
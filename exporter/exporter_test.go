@@ -0,0 +1,147 @@
+package exporter_test
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit/exporter"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func fixtures() fakeapi.Fixtures {
+	return fakeapi.Fixtures{
+		Nodes: []rabbithole.NodeInfo{
+			{Name: "rabbit@a", Running: true, FdUsed: 10, FdTotal: 100, MemUsed: 10, MemLimit: 100, DiskFree: 1000, DiskFreeLimit: 100, ProcUsed: 10, ProcTotal: 100, SocketsUsed: 1, SocketsTotal: 100},
+		},
+		Vhosts: []rabbithole.VhostInfo{{Name: "/"}},
+		Queues: []rabbithole.QueueInfo{
+			{Name: "orders", Vhost: "/", State: "running", Durable: true, MessagesRdy: 150, Messages: 150, MessagesPersistent: 150},
+		},
+		Consumers: []rabbithole.ConsumerInfo{
+			{ConsumerTag: "c1", PrefetchCount: 10, Queue: rabbithole.QueueDetail{Name: "orders", Vhost: "/"}},
+		},
+	}
+}
+
+/*
+gather scrapes the collector through a pedantic registry, which also checks Describe against Collect
+*/
+func gather(t *testing.T, c prometheus.Collector) map[string]*dto.MetricFamily {
+	t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+func labels(m *dto.Metric) map[string]string {
+	l := make(map[string]string)
+	for _, pair := range m.GetLabel() {
+		l[pair.GetName()] = pair.GetValue()
+	}
+	return l
+}
+
+func TestDescribe(t *testing.T) {
+	ch := make(chan *prometheus.Desc, 100)
+	exporter.NewCollector(nil).Describe(ch)
+	close(ch)
+
+	var names []string
+	for desc := range ch {
+		names = append(names, desc.String())
+	}
+	sort.Strings(names)
+
+	for _, want := range []string{
+		`fqName: "rabbitmonit_up"`,
+		`fqName: "rabbitmonit_alert"`,
+		`fqName: "rabbitmonit_queue_messages_ready"`,
+		`fqName: "rabbitmonit_queue_enqueue_dequeue_diff"`,
+		`fqName: "rabbitmonit_vhost_messages_ready"`,
+		`fqName: "rabbitmonit_node_running"`,
+	} {
+		found := false
+		for _, name := range names {
+			found = found || strings.Contains(name, want)
+		}
+		if !found {
+			t.Errorf("Describe did not send %s", want)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	srv := fakeapi.NewServer(fixtures())
+	defer srv.Close()
+
+	families := gather(t, exporter.NewCollector(srv.Ops()))
+
+	up := families["rabbitmonit_up"]
+	if up == nil || len(up.GetMetric()) != 3 {
+		t.Fatalf("rabbitmonit_up = %v, want the nodes, vhosts and queues parts", up)
+	}
+	for _, m := range up.GetMetric() {
+		if m.GetGauge().GetValue() != 1 {
+			t.Errorf("rabbitmonit_up%v = %v, want 1", labels(m), m.GetGauge().GetValue())
+		}
+	}
+
+	ready := families["rabbitmonit_queue_messages_ready"]
+	if ready == nil || len(ready.GetMetric()) != 1 {
+		t.Fatalf("rabbitmonit_queue_messages_ready = %v, want one series", ready)
+	}
+	if l := labels(ready.GetMetric()[0]); l["vhost"] != "/" || l["queue"] != "orders" || ready.GetMetric()[0].GetGauge().GetValue() != 150 {
+		t.Errorf("rabbitmonit_queue_messages_ready%v = %v, want {vhost=/, queue=orders} 150", l, ready.GetMetric()[0].GetGauge().GetValue())
+	}
+
+	if running := families["rabbitmonit_node_running"]; running == nil || labels(running.GetMetric()[0])["node"] != "rabbit@a" {
+		t.Errorf("rabbitmonit_node_running = %v, want a series for rabbit@a", running)
+	}
+
+	var rdy bool
+	for _, m := range families["rabbitmonit_alert"].GetMetric() {
+		l := labels(m)
+		if l["entity"] == "queue" && l["queue"] == "orders" && l["level"] == "error" && l["kind"] == "rdy" {
+			rdy = true
+		}
+	}
+	if !rdy {
+		t.Errorf("rabbitmonit_alert = %v, want the rdy error of orders", families["rabbitmonit_alert"])
+	}
+}
+
+func TestCollectFailedListing(t *testing.T) {
+	srv := fakeapi.NewServer(fixtures())
+	defer srv.Close()
+	srv.Fail("/api/queues", fakeapi.Failure{Status: http.StatusInternalServerError})
+
+	families := gather(t, exporter.NewCollector(srv.Ops()))
+
+	parts := make(map[string]float64)
+	for _, m := range families["rabbitmonit_up"].GetMetric() {
+		parts[labels(m)["part"]] = m.GetGauge().GetValue()
+	}
+	if parts["nodes"] != 1 || parts["vhosts"] != 1 || parts["queues"] != 0 {
+		t.Errorf("rabbitmonit_up = %v, want only the queues part down", parts)
+	}
+	if _, ok := families["rabbitmonit_queue_messages_ready"]; ok {
+		t.Error("queue series exported although the queue listing failed")
+	}
+	if _, ok := families["rabbitmonit_node_running"]; !ok {
+		t.Error("node series missing, the failed queue listing must not hide them")
+	}
+}
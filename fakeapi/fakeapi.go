@@ -0,0 +1,330 @@
+/*
+Package fakeapi is an in memory fake of the rabbitmq management api endpoints used by rabbit-monit.
+
+it runs on an httptest.Server and answers from programmable fixtures, so alerting scenarios can be
+tested deterministically without a broker:
+
+	srv := fakeapi.NewServer(fakeapi.Fixtures{Queues: queues})
+	defer srv.Close()
+
+	queues, err := srv.Ops().ListAccumulationQueues()
+*/
+package fakeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+Default credentials accepted by the server
+*/
+const (
+	Login    = "guest"
+	Password = "guest"
+)
+
+/*
+Fixtures is the state of the fake cluster
+*/
+type Fixtures struct {
+	Overview    rabbitmonit.OverviewInfo
+	Nodes       []rabbithole.NodeInfo
+	Vhosts      []rabbithole.VhostInfo
+	Queues      []rabbithole.QueueInfo
+	Consumers   []rabbithole.ConsumerInfo
+	Connections []rabbitmonit.ConnectionInfo
+	Exchanges   []rabbitmonit.ExchangeInfo
+	Bindings    []rabbithole.BindingInfo
+	Channels    []rabbitmonit.ChannelInfo
+}
+
+/*
+Failure makes an endpoint answer with an error instead of its fixtures
+*/
+type Failure struct {
+	Status int           // http status to answer with, 0 only applies Delay
+	Body   string        // response body, replaced by a json error when empty
+	Delay  time.Duration // wait before answering, eg. to trigger timeouts
+}
+
+/*
+Server is the fake management api
+*/
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fixtures Fixtures
+	failures map[string]Failure
+	requests map[string]int
+}
+
+/*
+NewServer starts a fake management api serving the given fixtures
+*/
+func NewServer(f Fixtures) *Server {
+	s := &Server{
+		fixtures: f,
+		failures: make(map[string]Failure),
+		requests: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+/*
+Ops returns an Ops pointed at the server with the default credentials
+*/
+func (s *Server) Ops() *rabbitmonit.Ops {
+	return &rabbitmonit.Ops{Host: s.URL, Login: Login, Password: Password}
+}
+
+/*
+SetFixtures replaces the whole state of the fake cluster
+*/
+func (s *Server) SetFixtures(f Fixtures) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures = f
+}
+
+/*
+Update changes the state of the fake cluster in place
+*/
+func (s *Server) Update(fn func(f *Fixtures)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.fixtures)
+}
+
+/*
+Fail makes every request to path (eg. "/api/queues/%2F") fail until Recover is called
+*/
+func (s *Server) Fail(path string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = failure
+}
+
+/*
+Recover removes the failure of path
+*/
+func (s *Server) Recover(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, path)
+}
+
+/*
+Requests returns how many requests were made to path, eg. "/api/consumers"
+*/
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+/*
+TotalRequests returns how many requests the server answered
+*/
+func (s *Server) TotalRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total int
+	for _, count := range s.requests {
+		total += count
+	}
+	return total
+}
+
+/*
+ResetRequests sets all the request counters back to zero
+*/
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = make(map[string]int)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+
+	s.mu.Lock()
+	s.requests[path]++
+	failure, failing := s.failures[path]
+	s.mu.Unlock()
+
+	if login, password, ok := r.BasicAuth(); !ok || login != Login || password != Password {
+		writeError(w, http.StatusUnauthorized, "not_authorised", "Login failed")
+		return
+	}
+
+	if failing {
+		if failure.Delay > 0 {
+			select {
+			case <-time.After(failure.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if failure.Status != 0 {
+			if failure.Body != "" {
+				w.WriteHeader(failure.Status)
+				w.Write([]byte(failure.Body))
+				return
+			}
+			writeError(w, failure.Status, "error", "injected failure")
+			return
+		}
+	}
+
+	segments, ok := splitPath(path)
+	if !ok || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "Object Not Found", "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	answer, found := s.route(segments)
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "Object Not Found", "Not Found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(answer)
+}
+
+/*
+route finds the fixtures answering the given path segments, the leading "api" excluded
+*/
+func (s *Server) route(segments []string) (interface{}, bool) {
+	f := &s.fixtures
+	resource, args := segments[0], segments[1:]
+
+	switch {
+	case resource == "overview" && len(args) == 0:
+		return f.Overview, true
+	case resource == "nodes" && len(args) == 0:
+		return nonNil(f.Nodes), true
+	case resource == "nodes" && len(args) == 1:
+		for _, node := range f.Nodes {
+			if node.Name == args[0] {
+				return node, true
+			}
+		}
+	case resource == "vhosts" && len(args) == 0:
+		return nonNil(f.Vhosts), true
+	case resource == "vhosts" && len(args) == 1:
+		for _, vhost := range f.Vhosts {
+			if vhost.Name == args[0] {
+				return vhost, true
+			}
+		}
+	case resource == "vhosts" && len(args) == 2 && args[1] == "connections":
+		connections := []rabbitmonit.ConnectionInfo{}
+		for _, connection := range f.Connections {
+			if connection.Vhost == args[0] {
+				connections = append(connections, connection)
+			}
+		}
+		return connections, true
+	case resource == "vhosts" && len(args) == 2 && args[1] == "channels":
+		channels := []rabbitmonit.ChannelInfo{}
+		for _, channel := range f.Channels {
+			if channel.Vhost == args[0] {
+				channels = append(channels, channel)
+			}
+		}
+		return channels, true
+	case resource == "queues" && len(args) <= 1:
+		queues := []rabbithole.QueueInfo{}
+		for _, queue := range f.Queues {
+			if len(args) == 0 || queue.Vhost == args[0] {
+				queues = append(queues, queue)
+			}
+		}
+		return queues, true
+	case resource == "queues" && len(args) == 2:
+		for _, queue := range f.Queues {
+			if queue.Vhost == args[0] && queue.Name == args[1] {
+				return queue, true
+			}
+		}
+	case resource == "consumers" && len(args) <= 1:
+		consumers := []rabbithole.ConsumerInfo{}
+		for _, consumer := range f.Consumers {
+			if len(args) == 0 || consumer.Queue.Vhost == args[0] {
+				consumers = append(consumers, consumer)
+			}
+		}
+		return consumers, true
+	case resource == "connections" && len(args) == 0:
+		return nonNil(f.Connections), true
+	case resource == "channels" && len(args) == 0:
+		return nonNil(f.Channels), true
+	case resource == "exchanges" && len(args) <= 1:
+		exchanges := []rabbitmonit.ExchangeInfo{}
+		for _, exchange := range f.Exchanges {
+			if len(args) == 0 || exchange.Vhost == args[0] {
+				exchanges = append(exchanges, exchange)
+			}
+		}
+		return exchanges, true
+	case resource == "bindings" && len(args) <= 1:
+		bindings := []rabbithole.BindingInfo{}
+		for _, binding := range f.Bindings {
+			if len(args) == 0 || binding.Vhost == args[0] {
+				bindings = append(bindings, binding)
+			}
+		}
+		return bindings, true
+	}
+	return nil, false
+}
+
+/*
+splitPath turns "/api/queues/%2F/name" into ["queues", "/", "name"]
+*/
+func splitPath(path string) ([]string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "api" {
+		return nil, false
+	}
+
+	segments := make([]string, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		segment, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, false
+		}
+		segments = append(segments, segment)
+	}
+	return segments, true
+}
+
+/*
+nonNil makes sure empty listings are encoded as [] like the real api does
+*/
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func writeError(w http.ResponseWriter, status int, kind, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": kind, "reason": reason})
+}
@@ -0,0 +1,114 @@
+package fakeapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+func fixtures() fakeapi.Fixtures {
+	return fakeapi.Fixtures{
+		Queues: []rabbithole.QueueInfo{
+			{Name: "orders", Vhost: "/", State: "running", Durable: true, MessagesRdy: 150, Messages: 150, MessagesPersistent: 150},
+			{Name: "audit", Vhost: "/billing", State: "running", Durable: true},
+		},
+		Consumers: []rabbithole.ConsumerInfo{
+			{ConsumerTag: "c1", PrefetchCount: 10, Queue: rabbithole.QueueDetail{Name: "orders", Vhost: "/"}},
+		},
+	}
+}
+
+func TestListAccumulationQueues(t *testing.T) {
+	srv := fakeapi.NewServer(fixtures())
+	defer srv.Close()
+
+	queues, err := srv.Ops().ListAccumulationQueues()
+	if err != nil {
+		t.Fatalf("ListAccumulationQueues: %v", err)
+	}
+
+	var names []string
+	for _, qp := range queues {
+		names = append(names, qp.QueueInfo.Vhost+qp.QueueInfo.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "/billingaudit" || names[1] != "/orders" {
+		t.Fatalf("queues = %v, want the two fixtures", names)
+	}
+	if queues[0].QueueInfo.Name != "orders" || !queues[0].Error.Rdy {
+		t.Errorf("first queue = %s with error %v, want orders raising rdy", queues[0].QueueInfo.Name, queues[0].Error.Kinds())
+	}
+	if got := srv.Requests("/api/queues"); got != 1 {
+		t.Errorf("Requests(/api/queues) = %d, want 1", got)
+	}
+	if got := srv.Requests("/api/consumers"); got != 1 {
+		t.Errorf("Requests(/api/consumers) = %d, want 1", got)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := fakeapi.NewServer(fixtures())
+	defer srv.Close()
+
+	ops := srv.Ops()
+	ops.Password = "wrong"
+	_, err := ops.ListNodes()
+	if !errors.Is(err, rabbitmonit.ErrUnauthorized) {
+		t.Fatalf("ListNodes with a wrong password = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestFailureDelay(t *testing.T) {
+	srv := fakeapi.NewServer(fixtures())
+	defer srv.Close()
+
+	srv.Fail("/api/nodes", fakeapi.Failure{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := srv.Ops().ListNodesContext(ctx)
+	if !errors.Is(err, rabbitmonit.ErrTimeout) {
+		t.Fatalf("ListNodesContext past the deadline = %v, want ErrTimeout", err)
+	}
+
+	srv.Recover("/api/nodes")
+	if _, err := srv.Ops().ListNodes(); err != nil {
+		t.Fatalf("ListNodes after Recover: %v", err)
+	}
+}
+
+func TestFailureStatus(t *testing.T) {
+	srv := fakeapi.NewServer(fixtures())
+	defer srv.Close()
+
+	srv.Fail("/api/vhosts", fakeapi.Failure{Status: http.StatusInternalServerError})
+	_, err := srv.Ops().ListVhosts()
+
+	var apiErr *rabbitmonit.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("ListVhosts = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("StatusCode = %d, want 500", apiErr.StatusCode)
+	}
+	if got := srv.Requests("/api/vhosts"); got != 1 {
+		t.Errorf("Requests(/api/vhosts) = %d, want 1", got)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	srv := fakeapi.NewServer(fixtures())
+	defer srv.Close()
+
+	_, err := srv.Ops().GetQueue("/", "missing")
+	if !errors.Is(err, rabbitmonit.ErrNotFound) {
+		t.Fatalf("GetQueue of a missing queue = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,193 @@
+package rabbitmonit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Fleet monitors several clusters at once. every call fans out to all the clusters concurrently,
+tags the results with the cluster name and sorts them worst first across the whole fleet
+*/
+type Fleet struct {
+	Clusters map[string]*Ops // the clusters by name
+}
+
+/*
+FleetError reports the clusters that failed during a Fleet call. the results of the other clusters
+are still returned next to it
+*/
+type FleetError struct {
+	Errors map[string]error // the error of every failed cluster
+}
+
+/*
+Error implements the error interface
+*/
+func (e *FleetError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %v", name, e.Errors[name])
+	}
+	return fmt.Sprintf("rabbitmonit: %d cluster(s) failed: %s", len(names), strings.Join(msgs, "; "))
+}
+
+/*
+Unwrap exposes the cluster errors to errors.Is/errors.As
+*/
+func (e *FleetError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+/*
+each runs fn for every cluster concurrently and collects the failures
+*/
+func (f *Fleet) each(fn func(name string, ops *Ops) error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := make(map[string]error)
+
+	for name, ops := range f.Clusters {
+		wg.Add(1)
+		go func(name string, ops *Ops) {
+			defer wg.Done()
+			if err := fn(name, ops); err != nil {
+				mu.Lock()
+				failed[name] = err
+				mu.Unlock()
+			}
+		}(name, ops)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return &FleetError{Errors: failed}
+	}
+	return nil
+}
+
+/*
+Nodes returns the nodes of all the clusters, worst first. a *FleetError lists the clusters that failed
+*/
+func (f *Fleet) Nodes(ctx context.Context) ([]NodeProperties, error) {
+	var mu sync.Mutex
+	var all []NodeProperties
+
+	err := f.each(func(name string, ops *Ops) error {
+		nodes, err := ops.ListNodesContext(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, node := range nodes {
+			node.Cluster = name
+			all = append(all, node)
+		}
+		return nil
+	})
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Level() != all[j].Level() {
+			return all[i].Level() > all[j].Level()
+		}
+		return all[i].Entity().String() < all[j].Entity().String()
+	})
+	return all, err
+}
+
+/*
+Vhosts returns the vhosts of all the clusters, worst first. a *FleetError lists the clusters that failed
+*/
+func (f *Fleet) Vhosts(ctx context.Context) ([]VhostProperties, error) {
+	var mu sync.Mutex
+	var all []VhostProperties
+
+	err := f.each(func(name string, ops *Ops) error {
+		vhosts, err := ops.ListVhostsContext(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, vhost := range vhosts {
+			vhost.Cluster = name
+			all = append(all, vhost)
+		}
+		return nil
+	})
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Level() != all[j].Level() {
+			return all[i].Level() > all[j].Level()
+		}
+		return all[i].VhostInfo.MessagesRdy > all[j].VhostInfo.MessagesRdy
+	})
+	return all, err
+}
+
+/*
+AccumulationQueues returns the queues of all the clusters, worst first. a *FleetError lists the clusters that failed
+*/
+func (f *Fleet) AccumulationQueues(ctx context.Context) ([]QueueProperties, error) {
+	var mu sync.Mutex
+	var all []QueueProperties
+
+	err := f.each(func(name string, ops *Ops) error {
+		queues, err := ops.ListAccumulationQueuesContext(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, queue := range queues {
+			queue.Cluster = name
+			all = append(all, queue)
+		}
+		return nil
+	})
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Level() != all[j].Level() {
+			return all[i].Level() > all[j].Level()
+		}
+		return all[i].QueueInfo.MessagesRdy > all[j].QueueInfo.MessagesRdy
+	})
+	return all, err
+}
+
+/*
+Reachable returns the clusters that answered the call that returned err, i.e. all the clusters
+but the ones listed by a *FleetError. pass them to the AlertStore Observe methods so the alerts
+of the failed clusters are kept until they answer again
+*/
+func (f *Fleet) Reachable(err error) []string {
+	var fe *FleetError
+	errors.As(err, &fe)
+
+	names := make([]string, 0, len(f.Clusters))
+	for name := range f.Clusters {
+		if fe != nil {
+			if _, failed := fe.Errors[name]; failed {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
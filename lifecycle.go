@@ -0,0 +1,502 @@
+package rabbitmonit
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Level is the severity of an entity as computed by Calculate
+*/
+type Level int
+
+const (
+	LevelOK Level = iota
+	LevelWarning
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelWarning:
+		return "warning"
+	case LevelError:
+		return "error"
+	}
+	return "ok"
+}
+
+/*
+MarshalText renders the level as "ok", "warning" or "error"
+*/
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+/*
+UnmarshalText parses the output of MarshalText
+*/
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "ok":
+		*l = LevelOK
+	case "warning":
+		*l = LevelWarning
+	case "error":
+		*l = LevelError
+	default:
+		return fmt.Errorf("rabbitmonit: unknown level %q", text)
+	}
+	return nil
+}
+
+/*
+Level returns the severity of the queue
+*/
+func (qp *QueueProperties) Level() Level {
+	return level(qp.Error.Has, qp.Warning.Has)
+}
+
+/*
+Level returns the severity of the vhost
+*/
+func (vp *VhostProperties) Level() Level {
+	return level(vp.Error.Has, vp.Warning.Has)
+}
+
+/*
+Level returns the severity of the exchange
+*/
+func (ep *ExchangeProperties) Level() Level {
+	return level(ep.Error.Has, ep.Warning.Has)
+}
+
+/*
+Level returns the severity of the connection
+*/
+func (cp *ConnectionProperties) Level() Level {
+	return level(cp.Error.Has, cp.Warning.Has)
+}
+
+/*
+Level returns the severity of the channel
+*/
+func (cp *ChannelProperties) Level() Level {
+	return level(cp.Error.Has, cp.Warning.Has)
+}
+
+/*
+Level returns the severity of the consumer
+*/
+func (cp *ConsumerProperties) Level() Level {
+	return level(cp.Error.Has, cp.Warning.Has)
+}
+
+/*
+Level returns the severity of the node. nodes have no Has flag so any raised flag counts
+*/
+func (np *NodeProperties) Level() Level {
+	return level(len(np.Error.Kinds()) > 0, len(np.Warning.Kinds()) > 0)
+}
+
+func level(hasError, hasWarning bool) Level {
+	if hasError {
+		return LevelError
+	}
+	if hasWarning {
+		return LevelWarning
+	}
+	return LevelOK
+}
+
+/*
+Entity identifies the queue, vhost, node, exchange, connection, channel or consumer an alert is about
+*/
+type Entity struct {
+	Cluster    string `json:"cluster,omitempty"` // only set for results coming from a Fleet
+	Type       string `json:"type"`              // "queue", "vhost", "node", "exchange", "connection", "channel" or "consumer"
+	Vhost      string `json:"vhost,omitempty"`
+	Queue      string `json:"queue,omitempty"`
+	Node       string `json:"node,omitempty"`
+	Exchange   string `json:"exchange,omitempty"`
+	Connection string `json:"connection,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	Consumer   string `json:"consumer,omitempty"` // consumer tag, unique within its channel
+}
+
+/*
+String renders the entity as "queue /vhost/name", "vhost /vhost", "node rabbit@host",
+"exchange /vhost/name", "connection 10.0.0.1:5000 -> 10.0.0.2:5672",
+"channel 10.0.0.1:5000 -> 10.0.0.2:5672 (1)" or "consumer /vhost/queue tag", prefixed
+with "[cluster] " when the cluster is known
+*/
+func (e Entity) String() string {
+	var prefix string
+	if e.Cluster != "" {
+		prefix = "[" + e.Cluster + "] "
+	}
+	switch e.Type {
+	case "queue":
+		return prefix + e.Type + " " + e.Vhost + "/" + e.Queue
+	case "vhost":
+		return prefix + e.Type + " " + e.Vhost
+	case "exchange":
+		return prefix + e.Type + " " + e.Vhost + "/" + e.Exchange
+	case "connection":
+		return prefix + e.Type + " " + e.Connection
+	case "channel":
+		return prefix + e.Type + " " + e.Channel
+	case "consumer":
+		return prefix + e.Type + " " + e.Vhost + "/" + e.Queue + " " + e.Consumer
+	}
+	return prefix + e.Type + " " + e.Node
+}
+
+/*
+Entity returns the entity of a queue
+*/
+func (qp *QueueProperties) Entity() Entity {
+	return Entity{Cluster: qp.Cluster, Type: "queue", Vhost: qp.QueueInfo.Vhost, Queue: qp.QueueInfo.Name}
+}
+
+/*
+Entity returns the entity of a vhost
+*/
+func (vp *VhostProperties) Entity() Entity {
+	return Entity{Cluster: vp.Cluster, Type: "vhost", Vhost: vp.VhostInfo.Name}
+}
+
+/*
+Entity returns the entity of a node
+*/
+func (np *NodeProperties) Entity() Entity {
+	return Entity{Cluster: np.Cluster, Type: "node", Node: np.NodeInfo.Name}
+}
+
+/*
+Entity returns the entity of an exchange
+*/
+func (ep *ExchangeProperties) Entity() Entity {
+	return Entity{Cluster: ep.Cluster, Type: "exchange", Vhost: ep.ExchangeInfo.Vhost, Exchange: ep.ExchangeInfo.Name}
+}
+
+/*
+Entity returns the entity of a connection
+*/
+func (cp *ConnectionProperties) Entity() Entity {
+	return Entity{Cluster: cp.Cluster, Type: "connection", Vhost: cp.ConnectionInfo.Vhost, Connection: cp.ConnectionInfo.Name}
+}
+
+/*
+Entity returns the entity of a channel
+*/
+func (cp *ChannelProperties) Entity() Entity {
+	return Entity{Cluster: cp.Cluster, Type: "channel", Vhost: cp.ChannelInfo.Vhost, Channel: cp.ChannelInfo.Name}
+}
+
+/*
+Entity returns the entity of a consumer
+*/
+func (cp *ConsumerProperties) Entity() Entity {
+	info := cp.ConsumerInfo
+	return Entity{Cluster: cp.Cluster, Type: "consumer", Vhost: info.Queue.Vhost, Queue: info.Queue.Name, Channel: info.ChannelDetails.Name, Consumer: info.ConsumerTag}
+}
+
+/*
+EventType describes a transition between two levels of an entity, or a change of its raised flags
+*/
+type EventType int
+
+const (
+	Firing     EventType = iota // ok -> warning/error
+	Escalated                   // warning -> error
+	Downgraded                  // error -> warning
+	Resolved                    // warning/error -> ok, or the entity disappeared
+	Changed                     // other flags raised at the same warning/error level
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Firing:
+		return "firing"
+	case Escalated:
+		return "escalated"
+	case Downgraded:
+		return "downgraded"
+	case Changed:
+		return "changed"
+	}
+	return "resolved"
+}
+
+/*
+MarshalText renders the event type as "firing", "escalated", "downgraded", "resolved" or "changed"
+*/
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+/*
+AlertState is what the store remembers about an entity in warning or error
+*/
+type AlertState struct {
+	Entity     Entity      `json:"entity"`
+	Level      Level       `json:"level"`
+	Kinds      []string    `json:"kinds"`       // the flags raised at Level
+	FirstSeen  time.Time   `json:"first_seen"`  // when the entity left the ok level
+	LevelSince time.Time   `json:"level_since"` // when the entity reached the current level
+	LastSeen   time.Time   `json:"last_seen"`
+	Stats      interface{} `json:"stats"` // the stats (QueueStat, NodeStat...) of the last observation
+}
+
+/*
+Event is emitted by AlertStore on every level transition, and when the flags raised at the same level change
+*/
+type Event struct {
+	Type      EventType     `json:"type"`
+	Entity    Entity        `json:"entity"`
+	Level     Level         `json:"level"`    // the level after the transition
+	Previous  Level         `json:"previous"` // the level before the transition
+	Kinds     []string      `json:"kinds"`    // the flags raised at Level, or at Previous for Resolved
+	FirstSeen time.Time     `json:"first_seen"`
+	At        time.Time     `json:"at"`
+	Duration  time.Duration `json:"duration"` // how long the entity has been out of the ok level
+	Stats     interface{}   `json:"stats"`    // the stats (QueueStat, NodeStat...) of the last observation
+}
+
+/*
+AlertStore diffs successive Calculate results and turns them into events.
+
+every Observe call is expected to carry the full list of entities of its type for the clusters it
+covers, entities of these clusters missing from it are resolved. the covered clusters are the ones
+passed to Observe, or when none is passed the clusters present in the list plus the unnamed cluster
+of a single Ops. alerts of the other clusters, e.g. the ones that failed during a Fleet call, are
+left untouched. AlertStore is safe for concurrent use
+*/
+type AlertStore struct {
+	mu     sync.Mutex
+	states map[Entity]*AlertState
+}
+
+/*
+NewAlertStore returns an empty store
+*/
+func NewAlertStore() *AlertStore {
+	return &AlertStore{states: make(map[Entity]*AlertState)}
+}
+
+/*
+ObserveQueues records the given queues and returns the resulting transitions
+*/
+func (s *AlertStore) ObserveQueues(now time.Time, queues []QueueProperties, clusters ...string) []Event {
+	return observeAll(s, now, "queue", queues, clusters, func(p *QueueProperties) ([]string, []string, interface{}) {
+		return p.Error.Kinds(), p.Warning.Kinds(), p.Stats
+	})
+}
+
+/*
+ObserveVhosts records the given vhosts and returns the resulting transitions
+*/
+func (s *AlertStore) ObserveVhosts(now time.Time, vhosts []VhostProperties, clusters ...string) []Event {
+	return observeAll(s, now, "vhost", vhosts, clusters, func(p *VhostProperties) ([]string, []string, interface{}) {
+		return p.Error.Kinds(), p.Warning.Kinds(), p.Stats
+	})
+}
+
+/*
+ObserveNodes records the given nodes and returns the resulting transitions
+*/
+func (s *AlertStore) ObserveNodes(now time.Time, nodes []NodeProperties, clusters ...string) []Event {
+	return observeAll(s, now, "node", nodes, clusters, func(p *NodeProperties) ([]string, []string, interface{}) {
+		return p.Error.Kinds(), p.Warning.Kinds(), p.Stats
+	})
+}
+
+/*
+ObserveExchanges records the given exchanges and returns the resulting transitions
+*/
+func (s *AlertStore) ObserveExchanges(now time.Time, exchanges []ExchangeProperties, clusters ...string) []Event {
+	return observeAll(s, now, "exchange", exchanges, clusters, func(p *ExchangeProperties) ([]string, []string, interface{}) {
+		return p.Error.Kinds(), p.Warning.Kinds(), p.Stats
+	})
+}
+
+/*
+ObserveConnections records the given connections and returns the resulting transitions
+*/
+func (s *AlertStore) ObserveConnections(now time.Time, connections []ConnectionProperties, clusters ...string) []Event {
+	return observeAll(s, now, "connection", connections, clusters, func(p *ConnectionProperties) ([]string, []string, interface{}) {
+		return p.Error.Kinds(), p.Warning.Kinds(), p.Stats
+	})
+}
+
+/*
+ObserveChannels records the given channels and returns the resulting transitions
+*/
+func (s *AlertStore) ObserveChannels(now time.Time, channels []ChannelProperties, clusters ...string) []Event {
+	return observeAll(s, now, "channel", channels, clusters, func(p *ChannelProperties) ([]string, []string, interface{}) {
+		return p.Error.Kinds(), p.Warning.Kinds(), p.Stats
+	})
+}
+
+/*
+ObserveConsumers records the given consumers and returns the resulting transitions
+*/
+func (s *AlertStore) ObserveConsumers(now time.Time, consumers []ConsumerProperties, clusters ...string) []Event {
+	return observeAll(s, now, "consumer", consumers, clusters, func(p *ConsumerProperties) ([]string, []string, interface{}) {
+		return p.Error.Kinds(), p.Warning.Kinds(), p.Stats
+	})
+}
+
+/*
+observeAll turns a Calculate result into observations. details returns the error kinds, the
+warning kinds and the stats of one entity
+*/
+func observeAll[T any, P interface {
+	*T
+	Entity() Entity
+	Level() Level
+}](s *AlertStore, now time.Time, entityType string, list []T, clusters []string, details func(P) ([]string, []string, interface{})) []Event {
+	observations := make([]observation, len(list))
+	for i := range list {
+		p := P(&list[i])
+		errs, warnings, stats := details(p)
+		observations[i] = observation{p.Entity(), p.Level(), levelKinds(p.Level(), errs, warnings), stats}
+	}
+	return s.observe(now, entityType, observations, clusters)
+}
+
+/*
+Active returns the entities currently in warning or error, worst and oldest first
+*/
+func (s *AlertStore) Active() []AlertState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make([]AlertState, 0, len(s.states))
+	for _, state := range s.states {
+		active = append(active, *state)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if active[i].Level != active[j].Level {
+			return active[i].Level > active[j].Level
+		}
+		return active[i].FirstSeen.Before(active[j].FirstSeen)
+	})
+	return active
+}
+
+type observation struct {
+	entity Entity
+	level  Level
+	kinds  []string
+	stats  interface{}
+}
+
+func levelKinds(l Level, errs, warnings []string) []string {
+	switch l {
+	case LevelError:
+		return errs
+	case LevelWarning:
+		return warnings
+	}
+	return nil
+}
+
+func (s *AlertStore) observe(now time.Time, entityType string, observations []observation, clusters []string) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []Event
+	seen := make(map[Entity]bool, len(observations))
+
+	covered := make(map[string]bool)
+	for _, cluster := range clusters {
+		covered[cluster] = true
+	}
+	if len(clusters) == 0 {
+		covered[""] = true
+		for _, o := range observations {
+			covered[o.entity.Cluster] = true
+		}
+	}
+
+	for _, o := range observations {
+		seen[o.entity] = true
+		state, known := s.states[o.entity]
+
+		switch {
+		case !known && o.level == LevelOK:
+			continue
+		case !known:
+			state = &AlertState{Entity: o.entity, Level: o.level, Kinds: o.kinds, FirstSeen: now, LevelSince: now, LastSeen: now, Stats: o.stats}
+			s.states[o.entity] = state
+			events = append(events, state.event(Firing, LevelOK, now))
+		case o.level == LevelOK:
+			state.Stats = o.stats
+			delete(s.states, o.entity)
+			events = append(events, state.event(Resolved, state.Level, now))
+		case o.level != state.Level:
+			previous := state.Level
+			state.Level, state.Kinds, state.LevelSince, state.LastSeen, state.Stats = o.level, o.kinds, now, now, o.stats
+			eventType := Escalated
+			if o.level < previous {
+				eventType = Downgraded
+			}
+			events = append(events, state.event(eventType, previous, now))
+		case !sameKinds(o.kinds, state.Kinds):
+			state.Kinds, state.LastSeen, state.Stats = o.kinds, now, o.stats
+			events = append(events, state.event(Changed, state.Level, now))
+		default:
+			state.LastSeen, state.Stats = now, o.stats
+		}
+	}
+
+	for entity, state := range s.states {
+		if entity.Type == entityType && covered[entity.Cluster] && !seen[entity] {
+			delete(s.states, entity)
+			events = append(events, state.event(Resolved, state.Level, now))
+		}
+	}
+
+	return events
+}
+
+/*
+sameKinds reports whether both lists hold the same flags, Kinds always lists them in the same order
+*/
+func sameKinds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+event builds the event for a transition of the state. for Resolved the state keeps the last
+raised level and kinds while the event level becomes ok
+*/
+func (state *AlertState) event(t EventType, previous Level, now time.Time) Event {
+	e := Event{
+		Type:      t,
+		Entity:    state.Entity,
+		Level:     state.Level,
+		Previous:  previous,
+		Kinds:     state.Kinds,
+		FirstSeen: state.FirstSeen,
+		At:        now,
+		Duration:  now.Sub(state.FirstSeen),
+		Stats:     state.Stats,
+	}
+	if t == Resolved {
+		e.Level = LevelOK
+	}
+	return e
+}
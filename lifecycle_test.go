@@ -0,0 +1,150 @@
+package rabbitmonit_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+func accumulating(name string) fakeapi.Fixtures {
+	return fakeapi.Fixtures{
+		Queues: []rabbithole.QueueInfo{
+			{Name: name, Vhost: "/", State: "running", Durable: true, MessagesRdy: 150, Messages: 150, MessagesPersistent: 150},
+		},
+		Consumers: []rabbithole.ConsumerInfo{
+			{ConsumerTag: name, PrefetchCount: 10, Queue: rabbithole.QueueDetail{Name: name, Vhost: "/"}},
+		},
+	}
+}
+
+func activeQueues(store *rabbitmonit.AlertStore) map[string]bool {
+	active := make(map[string]bool)
+	for _, state := range store.Active() {
+		active[state.Entity.String()] = true
+	}
+	return active
+}
+
+/*
+TestObserveFailedCluster checks that the alerts of a cluster failing during a Fleet call are kept
+*/
+func TestObserveFailedCluster(t *testing.T) {
+	a := fakeapi.NewServer(accumulating("qa"))
+	defer a.Close()
+	b := fakeapi.NewServer(accumulating("qb"))
+	defer b.Close()
+	fleet := &rabbitmonit.Fleet{Clusters: map[string]*rabbitmonit.Ops{"a": a.Ops(), "b": b.Ops()}}
+
+	store := rabbitmonit.NewAlertStore()
+	start := time.Now()
+
+	queues, err := fleet.AccumulationQueues(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := store.ObserveQueues(start, queues, fleet.Reachable(err)...); len(events) != 2 {
+		t.Fatalf("got %d events, want 2 firing", len(events))
+	}
+
+	b.Fail("/api/queues", fakeapi.Failure{Status: http.StatusInternalServerError})
+	queues, err = fleet.AccumulationQueues(context.Background())
+	if err == nil {
+		t.Fatal("expected a *FleetError for cluster b")
+	}
+	if events := store.ObserveQueues(start.Add(time.Minute), queues, fleet.Reachable(err)...); len(events) != 0 {
+		t.Errorf("got events %v, want none", events)
+	}
+	if active := activeQueues(store); !active["[b] queue //qb"] {
+		t.Errorf("active = %v, want the alert of the failed cluster kept", active)
+	}
+
+	b.Recover("/api/queues")
+	b.SetFixtures(fakeapi.Fixtures{})
+	queues, err = fleet.AccumulationQueues(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := store.ObserveQueues(start.Add(2*time.Minute), queues, fleet.Reachable(err)...)
+	if len(events) != 1 || events[0].Type != rabbitmonit.Resolved || events[0].Entity.String() != "[b] queue //qb" {
+		t.Errorf("got events %v, want [b] queue //qb resolved", events)
+	}
+}
+
+/*
+TestObservePerCluster checks that observing one cluster leaves the alerts of the others alone
+*/
+func TestObservePerCluster(t *testing.T) {
+	store := rabbitmonit.NewAlertStore()
+	now := time.Now()
+
+	for _, cluster := range []string{"a", "b"} {
+		srv := fakeapi.NewServer(accumulating("q" + cluster))
+		queues, err := srv.Ops().ListAccumulationQueues()
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range queues {
+			queues[i].Cluster = cluster
+		}
+		if events := store.ObserveQueues(now, queues); len(events) != 1 || events[0].Type != rabbitmonit.Firing {
+			t.Fatalf("cluster %s: got events %v, want one firing", cluster, events)
+		}
+	}
+
+	if active := activeQueues(store); len(active) != 2 {
+		t.Errorf("active = %v, want the alerts of both clusters", active)
+	}
+
+	if events := store.ObserveQueues(now, nil, "a"); len(events) != 1 || events[0].Entity.Cluster != "a" {
+		t.Errorf("got events %v, want only cluster a resolved", events)
+	}
+}
+
+func TestObserveKindsChanged(t *testing.T) {
+	queue := func(ready, consumers int) []rabbitmonit.QueueProperties {
+		info := rabbithole.QueueInfo{Name: "orders", Vhost: "/", State: "running", Durable: true,
+			MessagesRdy: ready, Messages: ready, MessagesPersistent: ready, Consumers: consumers, ConsumerUtilisation: 100.0}
+		return rabbitmonit.EvaluateQueues([]rabbithole.QueueInfo{info}, nil, nil)
+	}
+
+	store := rabbitmonit.NewAlertStore()
+	start := time.Now()
+	steps := []struct {
+		name      string
+		queues    []rabbitmonit.QueueProperties
+		wantType  []rabbitmonit.EventType
+		wantKinds []string
+	}{
+		{"firing", queue(150, 5), []rabbitmonit.EventType{rabbitmonit.Firing}, []string{"rdy"}},
+		{"flag added", queue(150, 0), []rabbitmonit.EventType{rabbitmonit.Changed}, []string{"rdy", "listener"}},
+		{"unchanged", queue(150, 0), nil, nil},
+		{"flag removed", queue(150, 5), []rabbitmonit.EventType{rabbitmonit.Changed}, []string{"rdy"}},
+		{"downgraded", queue(50, 5), []rabbitmonit.EventType{rabbitmonit.Downgraded}, []string{"rdy"}},
+	}
+
+	for i, step := range steps {
+		events := store.ObserveQueues(start.Add(time.Duration(i)*time.Minute), step.queues)
+		var types []rabbitmonit.EventType
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		if len(types) != len(step.wantType) || (len(types) == 1 && types[0] != step.wantType[0]) {
+			t.Fatalf("%s: got events %v, want %v", step.name, types, step.wantType)
+		}
+		if len(events) == 1 && strings.Join(events[0].Kinds, ",") != strings.Join(step.wantKinds, ",") {
+			t.Errorf("%s: kinds = %v, want %v", step.name, events[0].Kinds, step.wantKinds)
+		}
+	}
+
+	active := store.Active()
+	if len(active) != 1 || !active[0].LevelSince.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("active = %v, want the warning since the downgrade", active)
+	}
+}
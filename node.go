@@ -6,10 +6,12 @@ import "github.com/c-datculescu/rabbit-hole"
 NodeProperties is a structure offering slightly more flexibility/statistics than the rabbit-hole struct
 */
 type NodeProperties struct {
-	Stats    NodeStat
-	Error    NodeAlert
-	Warning  NodeAlert
-	NodeInfo rabbithole.NodeInfo
+	Stats      NodeStat
+	Error      NodeAlert
+	Warning    NodeAlert
+	NodeInfo   rabbithole.NodeInfo
+	Cluster    string          // name of the cluster the node belongs to, set by Fleet
+	Thresholds *NodeThresholds // the limits used for alerting, nil means DefaultNodeThresholds
 }
 
 /*
@@ -39,6 +41,39 @@ type NodeAlert struct {
 	Status bool // status of the node. if status is not "running", error
 }
 
+/*
+Kinds returns the names of the flags that are set
+*/
+func (na NodeAlert) Kinds() []string {
+	var kinds []string
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{na.Fd, "fd"},
+		{na.Erl, "erl"},
+		{na.Mem, "mem"},
+		{na.Hdd, "hdd"},
+		{na.Sock, "sock"},
+		{na.Status, "status"},
+	} {
+		if flag.set {
+			kinds = append(kinds, flag.name)
+		}
+	}
+	return kinds
+}
+
+/*
+thresholds returns the limits to be used for the current node
+*/
+func (np *NodeProperties) thresholds() NodeThresholds {
+	if np.Thresholds != nil {
+		return *np.Thresholds
+	}
+	return DefaultNodeThresholds()
+}
+
 /*
 statsFd calculates the stats related to file descriptors available for rabbitmq
 */
@@ -87,14 +122,15 @@ func (np *NodeProperties) statsSock() *NodeProperties {
 /*
 alertFd calculates whether it should raise an alert or a warning for file descriptors
 
-if file descriptors are over 90% than an alert is raised
+if file descriptors are over the error threshold (default 90%) than an alert is raised
 
-if file descriptors are over 80% a warning gets raised
+if file descriptors are over the warning threshold (default 80%) a warning gets raised
 */
 func (np *NodeProperties) alertFd() *NodeProperties {
-	if np.Stats.FdUsedPercentage > 90 {
+	th := np.thresholds()
+	if np.Stats.FdUsedPercentage > th.FdError {
 		np.Error.Fd = true
-	} else if np.Stats.FdUsedPercentage > 80 {
+	} else if np.Stats.FdUsedPercentage > th.FdWarning {
 		np.Warning.Fd = true
 	}
 	return np
@@ -103,14 +139,15 @@ func (np *NodeProperties) alertFd() *NodeProperties {
 /*
 alertErl caulculates whetger it should raise an alert or a warning for erlang processes availability
 
-if erlang processes are over 90% it raises an alert
+if erlang processes are over the error threshold (default 90%) it raises an alert
 
-if erlang processes are over 80% it raises a warning
+if erlang processes are over the warning threshold (default 80%) it raises a warning
 */
 func (np *NodeProperties) alertErl() *NodeProperties {
-	if np.Stats.ErlUsedPercentage > 90 {
+	th := np.thresholds()
+	if np.Stats.ErlUsedPercentage > th.ErlError {
 		np.Error.Erl = true
-	} else if np.Stats.ErlUsedPercentage > 80 {
+	} else if np.Stats.ErlUsedPercentage > th.ErlWarning {
 		np.Warning.Erl = true
 	}
 	return np
@@ -119,14 +156,15 @@ func (np *NodeProperties) alertErl() *NodeProperties {
 /*
 alertMem calculates whether it should raise an alert or a warning for memory approaching the alert threshold
 
-if memory is over 90% an alert is raised
+if memory is over the error threshold (default 90%) an alert is raised
 
-if memory is over 85% a warning is raised
+if memory is over the warning threshold (default 85%) a warning is raised
 */
 func (np *NodeProperties) alertMem() *NodeProperties {
-	if np.Stats.MemUsedPercentage > 90 {
+	th := np.thresholds()
+	if np.Stats.MemUsedPercentage > th.MemError {
 		np.Error.Mem = true
-	} else if np.Stats.MemUsedPercentage > 85 {
+	} else if np.Stats.MemUsedPercentage > th.MemWarning {
 		np.Warning.Mem = true
 	}
 
@@ -136,14 +174,15 @@ func (np *NodeProperties) alertMem() *NodeProperties {
 /*
 alertHdd calculates whether there should be an alert or a warning for disk space approaching the alerting threshold
 
-if disk space is over 90% an alert is raised
+if disk space is over the error threshold (default 90%) an alert is raised
 
-if disk space is over 80% an warning is raised
+if disk space is over the warning threshold (default 80%) an warning is raised
 */
 func (np *NodeProperties) alertHdd() *NodeProperties {
-	if np.Stats.DiskUsedPercentage > 90 {
+	th := np.thresholds()
+	if np.Stats.DiskUsedPercentage > th.HddError {
 		np.Error.Hdd = true
-	} else if np.Stats.DiskUsedPercentage > 80 {
+	} else if np.Stats.DiskUsedPercentage > th.HddWarning {
 		np.Warning.Hdd = true
 	}
 	return np
@@ -152,14 +191,15 @@ func (np *NodeProperties) alertHdd() *NodeProperties {
 /*
 alertSock calculates whetjer there should be an alert or a warning for socket exhaustion
 
-if socket consumption is 90% or over an alert is raised
+if socket consumption is at the error threshold (default 90%) or over an alert is raised
 
-if socket consumption is 80% or over a warning is raised
+if socket consumption is at the warning threshold (default 80%) or over a warning is raised
 */
 func (np *NodeProperties) alertSock() *NodeProperties {
-	if np.Stats.SockUsedPercentage > 90 {
+	th := np.thresholds()
+	if np.Stats.SockUsedPercentage > th.SockError {
 		np.Error.Sock = true
-	} else if np.Stats.SockUsedPercentage > 80 {
+	} else if np.Stats.SockUsedPercentage > th.SockWarning {
 		np.Warning.Sock = true
 	}
 	return np
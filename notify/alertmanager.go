@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+Alertmanager pushes the active alerts to a prometheus alertmanager through its v2 api.
+
+alertmanager expects firing alerts to be sent again periodically, so Sync is meant to be called after
+every poll with AlertStore.Active(). every raised flag becomes one alert labelled with entity, vhost,
+queue, node, kind and severity. an alert starts at the first Sync sending it, so a kind raised later
+than the level of its entity gets its own start. alerts that stop being active are sent once more with
+endsAt set to the time they went away, until alertmanager accepts them
+*/
+type Alertmanager struct {
+	URL          string            // base url of alertmanager, eg. http://localhost:9093
+	Labels       map[string]string // static labels added to every alert, eg. cluster
+	GeneratorURL string            // optional link back to the source of the alerts
+	TTL          time.Duration     // how long an alert stays firing without being sent again, defaults to five minutes
+	Client       *http.Client      // defaults to http.DefaultClient
+
+	mu   sync.Mutex
+	sent map[string]postableAlert // alerts sent as firing by the previous Sync
+}
+
+/*
+postableAlert is the alertmanager v2 representation of an alert
+*/
+type postableAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+/*
+Sync sends the active alerts as firing and the ones that disappeared since the previous call as resolved
+*/
+func (a *Alertmanager) Sync(ctx context.Context, now time.Time, active []rabbitmonit.AlertState) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ttl := a.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	current := make(map[string]postableAlert)
+	var alerts []postableAlert
+
+	for _, state := range active {
+		for _, kind := range state.Kinds {
+			alert := a.alert(state, kind)
+			key := alertKey(alert.Labels)
+			alert.StartsAt = now
+			if previous, ok := a.sent[key]; ok && previous.EndsAt.After(now) {
+				// still firing since the previous Sync, resolved alerts waiting to be sent start over
+				alert.StartsAt = previous.StartsAt
+			}
+			alert.EndsAt = now.Add(ttl)
+			current[key] = alert
+			alerts = append(alerts, alert)
+		}
+	}
+
+	resolved := make(map[string]postableAlert)
+	for key, alert := range a.sent {
+		if _, ok := current[key]; !ok {
+			// an alert whose resolution failed to be sent keeps its original endsAt
+			if alert.EndsAt.After(now) {
+				alert.EndsAt = now
+			}
+			resolved[key] = alert
+			alerts = append(alerts, alert)
+		}
+	}
+
+	if len(alerts) > 0 {
+		if err := a.post(ctx, alerts); err != nil {
+			// keep the resolutions around so they are sent again by the next Sync
+			for key, alert := range resolved {
+				current[key] = alert
+			}
+			a.sent = current
+			return err
+		}
+	}
+
+	a.sent = current
+	return nil
+}
+
+/*
+alert builds the alertmanager alert for one flag of an entity, Sync sets its start and end
+*/
+func (a *Alertmanager) alert(state rabbitmonit.AlertState, kind string) postableAlert {
+	labels := map[string]string{
+		"alertname": "RabbitMonit",
+		"entity":    state.Entity.Type,
+		"kind":      kind,
+		"severity":  state.Level.String(),
+	}
+	for key, value := range map[string]string{"cluster": state.Entity.Cluster, "vhost": state.Entity.Vhost, "queue": state.Entity.Queue, "node": state.Entity.Node, "exchange": state.Entity.Exchange, "connection": state.Entity.Connection, "channel": state.Entity.Channel, "consumer": state.Entity.Consumer} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	for key, value := range a.Labels {
+		labels[key] = value
+	}
+
+	annotations := map[string]string{
+		"summary":    fmt.Sprintf("%s %s: %s", state.Entity, state.Level, kind),
+		"first_seen": state.FirstSeen.Format(time.RFC3339),
+	}
+	if state.Stats != nil {
+		if stats, err := json.Marshal(state.Stats); err == nil {
+			annotations["stats"] = string(stats)
+		}
+	}
+
+	return postableAlert{
+		Labels:       labels,
+		Annotations:  annotations,
+		GeneratorURL: a.GeneratorURL,
+	}
+}
+
+/*
+alertKey identifies an alert by its labels. severity is part of it so an escalation resolves the
+warning alert and fires the error one
+*/
+func alertKey(labels map[string]string) string {
+	return strings.Join([]string{labels["cluster"], labels["entity"], labels["vhost"], labels["queue"], labels["node"], labels["exchange"], labels["connection"], labels["channel"], labels["consumer"], labels["kind"], labels["severity"]}, "\x00")
+}
+
+/*
+post sends the alerts to /api/v2/alerts
+*/
+func (a *Alertmanager) post(ctx context.Context, alerts []postableAlert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(a.URL, "/") + "/api/v2/alerts"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("notify: alertmanager answered %s: %s", res.Status, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,170 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/notify"
+)
+
+type postedAlert struct {
+	Labels   map[string]string `json:"labels"`
+	StartsAt time.Time         `json:"startsAt"`
+	EndsAt   time.Time         `json:"endsAt"`
+}
+
+/*
+alertmanager is a fake /api/v2/alerts endpoint recording every accepted post
+*/
+type alertmanager struct {
+	mu    sync.Mutex
+	fail  bool
+	posts [][]postedAlert
+}
+
+func (am *alertmanager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if r.URL.Path != "/api/v2/alerts" {
+		http.NotFound(w, r)
+		return
+	}
+	if am.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var alerts []postedAlert
+	if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	am.posts = append(am.posts, alerts)
+}
+
+func (am *alertmanager) last(t *testing.T) map[string]postedAlert {
+	t.Helper()
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if len(am.posts) == 0 {
+		t.Fatal("nothing was posted")
+	}
+	alerts := make(map[string]postedAlert)
+	for _, alert := range am.posts[len(am.posts)-1] {
+		alerts[alert.Labels["severity"]+" "+alert.Labels["kind"]] = alert
+	}
+	am.posts = nil
+	return alerts
+}
+
+func TestAlertmanagerSync(t *testing.T) {
+	am := &alertmanager{}
+	srv := httptest.NewServer(am)
+	defer srv.Close()
+
+	a := &notify.Alertmanager{URL: srv.URL, TTL: time.Minute}
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	entity := rabbitmonit.Entity{Type: "queue", Vhost: "/", Queue: "orders"}
+
+	warning := rabbitmonit.AlertState{Entity: entity, Level: rabbitmonit.LevelWarning, Kinds: []string{"rdy"}, FirstSeen: start, LevelSince: start, LastSeen: start}
+	if err := a.Sync(ctx, start, []rabbitmonit.AlertState{warning}); err != nil {
+		t.Fatal(err)
+	}
+	alerts := am.last(t)
+	fired, ok := alerts["warning rdy"]
+	if len(alerts) != 1 || !ok {
+		t.Fatalf("fired %v, want the warning rdy alert", alerts)
+	}
+	if !fired.StartsAt.Equal(start) || !fired.EndsAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("firing alert runs %s - %s, want %s - %s", fired.StartsAt, fired.EndsAt, start, start.Add(time.Minute))
+	}
+	if fired.Labels["queue"] != "orders" || fired.Labels["vhost"] != "/" || fired.Labels["entity"] != "queue" {
+		t.Errorf("labels = %v", fired.Labels)
+	}
+
+	// re-send: the same alert keeps its start and gets a new endsAt
+	now := start.Add(30 * time.Second)
+	warning.LastSeen = now
+	if err := a.Sync(ctx, now, []rabbitmonit.AlertState{warning}); err != nil {
+		t.Fatal(err)
+	}
+	alerts = am.last(t)
+	if resent := alerts["warning rdy"]; len(alerts) != 1 || !resent.StartsAt.Equal(start) || !resent.EndsAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("re-sent %v, want warning rdy from %s to %s", alerts, start, now.Add(time.Minute))
+	}
+
+	// escalation: the warning is resolved and the error fires from the time it was reached
+	escalated := start.Add(time.Minute)
+	errState := warning
+	errState.Level, errState.LevelSince, errState.LastSeen = rabbitmonit.LevelError, escalated, escalated
+	if err := a.Sync(ctx, escalated, []rabbitmonit.AlertState{errState}); err != nil {
+		t.Fatal(err)
+	}
+	alerts = am.last(t)
+	if len(alerts) != 2 {
+		t.Fatalf("escalation posted %v, want the warning resolved and the error fired", alerts)
+	}
+	if resolved := alerts["warning rdy"]; !resolved.EndsAt.Equal(escalated) {
+		t.Errorf("warning ends at %s, want %s", resolved.EndsAt, escalated)
+	}
+	if fired := alerts["error rdy"]; !fired.StartsAt.Equal(escalated) {
+		t.Errorf("error starts at %s, want the escalation time %s", fired.StartsAt, escalated)
+	}
+
+	// a kind raised at the same level starts on its own, the other one keeps its start
+	added := start.Add(90 * time.Second)
+	errState.Kinds, errState.LastSeen = []string{"rdy", "listener"}, added
+	if err := a.Sync(ctx, added, []rabbitmonit.AlertState{errState}); err != nil {
+		t.Fatal(err)
+	}
+	alerts = am.last(t)
+	if fired := alerts["error listener"]; len(alerts) != 2 || !fired.StartsAt.Equal(added) {
+		t.Errorf("posted %v, want error listener starting at %s", alerts, added)
+	}
+	if kept := alerts["error rdy"]; !kept.StartsAt.Equal(escalated) {
+		t.Errorf("error rdy starts at %s, want %s", kept.StartsAt, escalated)
+	}
+
+	// resolution while alertmanager is down: the error is sent resolved once it is back
+	gone := start.Add(2 * time.Minute)
+	am.mu.Lock()
+	am.fail = true
+	am.mu.Unlock()
+	if err := a.Sync(ctx, gone, nil); err == nil {
+		t.Fatal("expected the failed post to be reported")
+	}
+
+	am.mu.Lock()
+	am.fail = false
+	am.mu.Unlock()
+	if err := a.Sync(ctx, gone.Add(time.Minute), nil); err != nil {
+		t.Fatal(err)
+	}
+	alerts = am.last(t)
+	if len(alerts) != 2 {
+		t.Errorf("retry posted %v, want error rdy and listener resolved", alerts)
+	}
+	for _, kind := range []string{"error rdy", "error listener"} {
+		if resolved, ok := alerts[kind]; !ok || !resolved.EndsAt.Equal(gone) {
+			t.Errorf("retry posted %s = %v, want it resolved at %s", kind, resolved, gone)
+		}
+	}
+
+	// once accepted the resolution is not sent again
+	if err := a.Sync(ctx, gone.Add(2*time.Minute), nil); err != nil {
+		t.Fatal(err)
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	if len(am.posts) != 0 {
+		t.Errorf("posted %v after the resolution was accepted, want nothing", am.posts)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Package notify delivers rabbit-monit alert transitions to external systems
+*/
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+Notifier delivers a single alert transition
+*/
+type Notifier interface {
+	Notify(ctx context.Context, event rabbitmonit.Event) error
+}
+
+/*
+Payload is the default json body sent for an event, and the data available to body templates
+*/
+type Payload struct {
+	Event     string             `json:"event"` // firing, escalated, downgraded, resolved or changed
+	Level     string             `json:"level"`
+	Previous  string             `json:"previous"`
+	Entity    rabbitmonit.Entity `json:"entity"`
+	Kinds     []string           `json:"kinds"` // the alert flags that are set
+	Stats     interface{}        `json:"stats"`
+	FirstSeen time.Time          `json:"first_seen"`
+	At        time.Time          `json:"at"`
+	Duration  string             `json:"duration"`
+	Summary   string             `json:"summary"` // a one line human readable description
+}
+
+/*
+NewPayload builds the payload for an event
+*/
+func NewPayload(event rabbitmonit.Event) Payload {
+	return Payload{
+		Event:     event.Type.String(),
+		Level:     event.Level.String(),
+		Previous:  event.Previous.String(),
+		Entity:    event.Entity,
+		Kinds:     event.Kinds,
+		Stats:     event.Stats,
+		FirstSeen: event.FirstSeen,
+		At:        event.At,
+		Duration:  event.Duration.Round(time.Second).String(),
+		Summary:   Summary(event),
+	}
+}
+
+/*
+Summary describes an event in one line, eg. "queue /billing/audit.log firing: error [rdy,listener]"
+*/
+func Summary(event rabbitmonit.Event) string {
+	summary := fmt.Sprintf("%s %s: %s", event.Entity, event.Type, event.Level)
+	if event.Type == rabbitmonit.Resolved {
+		summary = fmt.Sprintf("%s %s after %s", event.Entity, event.Type, event.Duration.Round(time.Second))
+	}
+	if len(event.Kinds) > 0 && event.Type != rabbitmonit.Resolved {
+		summary += " [" + strings.Join(event.Kinds, ",") + "]"
+	}
+	return summary
+}
@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+SlackTemplate is a body template for slack compatible incoming webhooks
+*/
+const SlackTemplate = `{"text": {{ json .Summary }}}`
+
+/*
+TeamsTemplate is a body template for microsoft teams incoming webhooks
+*/
+const TeamsTemplate = `{"@type": "MessageCard", "@context": "https://schema.org/extensions",` +
+	` "themeColor": {{ if eq .Level "error" }}"d9534f"{{ else if eq .Level "warning" }}"f0ad4e"{{ else }}"5cb85c"{{ end }},` +
+	` "summary": {{ json .Summary }}, "text": {{ json .Summary }}}`
+
+/*
+Webhook POSTs every event to an http endpoint.
+
+without a Template the body is the json encoded Payload. the template is compiled once, by NewWebhook or
+by the first Notify, and must not change afterwards. failed deliveries (network errors, 429 and 5xx) are
+retried up to MaxRetries times, waiting Backoff before the first retry and doubling it every time.
+a Webhook is safe for concurrent use
+*/
+type Webhook struct {
+	URL         string
+	Template    string            // text/template rendering the body out of a Payload, the json function is available
+	ContentType string            // defaults to application/json
+	Headers     map[string]string // additional request headers
+	MaxRetries  int
+	Backoff     time.Duration // defaults to one second
+	Client      *http.Client  // defaults to http.DefaultClient
+
+	compiled sync.Once
+	tmpl     *template.Template
+	tmplErr  error
+}
+
+/*
+NewWebhook returns a webhook for url, tmpl may be empty, "slack", "teams" or a template
+*/
+func NewWebhook(url, tmpl string) (*Webhook, error) {
+	switch tmpl {
+	case "slack":
+		tmpl = SlackTemplate
+	case "teams":
+		tmpl = TeamsTemplate
+	}
+
+	w := &Webhook{URL: url, Template: tmpl, MaxRetries: 3}
+	if err := w.compile(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+/*
+compile parses the template of the webhook on the first call, the following calls return the same outcome
+*/
+func (w *Webhook) compile() error {
+	w.compiled.Do(func() {
+		if w.Template == "" {
+			return
+		}
+
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+			"join": strings.Join,
+		}).Parse(w.Template)
+		if err != nil {
+			w.tmplErr = fmt.Errorf("notify: webhook template: %v", err)
+			return
+		}
+		w.tmpl = tmpl
+	})
+	return w.tmplErr
+}
+
+/*
+body renders the request body for the event
+*/
+func (w *Webhook) body(event rabbitmonit.Event) ([]byte, error) {
+	if err := w.compile(); err != nil {
+		return nil, err
+	}
+
+	payload := NewPayload(event)
+	if w.tmpl == nil {
+		return json.Marshal(payload)
+	}
+
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+Notify implements Notifier
+*/
+func (w *Webhook) Notify(ctx context.Context, event rabbitmonit.Event) error {
+	body, err := w.body(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+/*
+post sends the body once, reporting whether a failure is worth retrying
+*/
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	contentType := w.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	return retry, fmt.Errorf("notify: webhook answered %s", res.Status)
+}
@@ -0,0 +1,201 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/notify"
+)
+
+/*
+receiver is a fake webhook endpoint answering the given statuses in turn, then 200
+*/
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.bodies = append(rc.bodies, string(body))
+	rc.headers = append(rc.headers, r.Header)
+	if len(rc.statuses) > 0 {
+		status := rc.statuses[0]
+		rc.statuses = rc.statuses[1:]
+		w.WriteHeader(status)
+	}
+}
+
+func (rc *receiver) calls() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.bodies)
+}
+
+func firing() rabbitmonit.Event {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return rabbitmonit.Event{
+		Type:      rabbitmonit.Firing,
+		Entity:    rabbitmonit.Entity{Type: "queue", Vhost: "/billing", Queue: "audit.log"},
+		Level:     rabbitmonit.LevelError,
+		Previous:  rabbitmonit.LevelOK,
+		Kinds:     []string{"rdy", "listener"},
+		FirstSeen: at,
+		At:        at,
+	}
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	w, err := notify.NewWebhook(srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Headers = map[string]string{"Authorization": "Bearer token"}
+	if err := w.Notify(context.Background(), firing()); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload notify.Payload
+	if err := json.Unmarshal([]byte(rc.bodies[0]), &payload); err != nil {
+		t.Fatalf("body %q is not a payload: %v", rc.bodies[0], err)
+	}
+	if payload.Event != "firing" || payload.Level != "error" || payload.Entity.Queue != "audit.log" || len(payload.Kinds) != 2 {
+		t.Errorf("payload = %+v", payload)
+	}
+	if got := rc.headers[0].Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := rc.headers[0].Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"custom", `{{ .Event }} {{ .Entity }} {{ join .Kinds "+" }}`, "firing queue /billing/audit.log rdy+listener"},
+		{"slack", "slack", `{"text": "queue /billing/audit.log firing: error [rdy,listener]"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{}
+			srv := httptest.NewServer(rc)
+			defer srv.Close()
+
+			w, err := notify.NewWebhook(srv.URL, tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Notify(context.Background(), firing()); err != nil {
+				t.Fatal(err)
+			}
+			if rc.bodies[0] != tt.want {
+				t.Errorf("body = %q, want %q", rc.bodies[0], tt.want)
+			}
+		})
+	}
+
+	if _, err := notify.NewWebhook("http://localhost", "{{ .Event "); err == nil {
+		t.Error("NewWebhook accepted an invalid template")
+	}
+}
+
+/*
+TestWebhookConcurrentNotify compiles the template of a webhook built without NewWebhook from concurrent
+Notify calls, run with -race
+*/
+func TestWebhookConcurrentNotify(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	w := &notify.Webhook{URL: srv.URL, Template: `{{ .Event }}`}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Notify(context.Background(), firing()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if rc.calls() != 8 {
+		t.Errorf("got %d requests, want 8", rc.calls())
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		calls    int
+	}{
+		{"retry on 5xx", []int{http.StatusBadGateway, http.StatusServiceUnavailable}, false, 3},
+		{"retry on 429", []int{http.StatusTooManyRequests}, false, 2},
+		{"give up after MaxRetries", []int{500, 500, 500, 500, 500}, true, 3},
+		{"no retry on 4xx", []int{http.StatusBadRequest}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{statuses: tt.statuses}
+			srv := httptest.NewServer(rc)
+			defer srv.Close()
+
+			w := &notify.Webhook{URL: srv.URL, MaxRetries: 2, Backoff: time.Millisecond}
+			err := w.Notify(context.Background(), firing())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Notify = %v, want error %v", err, tt.wantErr)
+			}
+			if rc.calls() != tt.calls {
+				t.Errorf("got %d requests, want %d", rc.calls(), tt.calls)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoffCancel(t *testing.T) {
+	rc := &receiver{statuses: []int{500, 500, 500}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	w := &notify.Webhook{URL: srv.URL, MaxRetries: 3, Backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err := w.Notify(ctx, firing())
+	if err != context.DeadlineExceeded {
+		t.Errorf("Notify = %v, want the ctx error", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Notify took %s, want the backoff interrupted by ctx", elapsed)
+	}
+	if rc.calls() != 1 {
+		t.Errorf("got %d requests, want 1 before the backoff", rc.calls())
+	}
+}
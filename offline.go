@@ -0,0 +1,81 @@
+package rabbitmonit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+/*
+SnapshotFiles points at json captured from the management api, eg. attached to an incident ticket.
+every field is optional, "-" reads from stdin (only one of them can)
+*/
+type SnapshotFiles struct {
+	Queues      string // output of /api/queues
+	Nodes       string // output of /api/nodes
+	Vhosts      string // output of /api/vhosts
+	Consumers   string // output of /api/consumers, without it the unacked messages alert is skipped
+	Connections string // output of /api/connections
+	Channels    string // output of /api/channels
+	Overview    string // output of /api/overview
+}
+
+/*
+LoadSnapshotFiles builds and evaluates a snapshot out of captured management api json, th may be nil for
+the default thresholds. the files carry no capture time, connection ages are measured up to now
+*/
+func LoadSnapshotFiles(files SnapshotFiles, th *Thresholds) (*ClusterSnapshot, error) {
+	s := &ClusterSnapshot{Timestamp: time.Now()}
+	stdinUsed := false
+
+	for _, part := range []struct {
+		path string
+		out  interface{}
+	}{
+		{files.Queues, &s.Queues},
+		{files.Nodes, &s.Nodes},
+		{files.Vhosts, &s.Vhosts},
+		{files.Consumers, &s.Consumers},
+		{files.Connections, &s.Connections},
+		{files.Channels, &s.Channels},
+		{files.Overview, &s.Overview},
+	} {
+		if part.path == "" {
+			continue
+		}
+		if part.path == "-" {
+			if stdinUsed {
+				return nil, fmt.Errorf("rabbitmonit: only one input can be read from stdin")
+			}
+			stdinUsed = true
+		}
+		if err := decodeFile(part.path, part.out); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Evaluate(th)
+	return s, nil
+}
+
+/*
+decodeFile decodes the json found at path, "-" being stdin
+*/
+func decodeFile(path string, out interface{}) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if err := json.NewDecoder(r).Decode(out); err != nil {
+		return fmt.Errorf("rabbitmonit: %s: %w", path, err)
+	}
+	return nil
+}
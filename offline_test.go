@@ -0,0 +1,95 @@
+package rabbitmonit_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+/*
+snapshotFiles points at the management api json saved in testdata/snapshot
+*/
+func snapshotFiles() rabbitmonit.SnapshotFiles {
+	dir := filepath.Join("testdata", "snapshot")
+	return rabbitmonit.SnapshotFiles{
+		Queues:    filepath.Join(dir, "queues.json"),
+		Nodes:     filepath.Join(dir, "nodes.json"),
+		Vhosts:    filepath.Join(dir, "vhosts.json"),
+		Consumers: filepath.Join(dir, "consumers.json"),
+	}
+}
+
+/*
+snapshotLevels returns the level of every node, vhost and queue of s, keyed by entity
+*/
+func snapshotLevels(s *rabbitmonit.ClusterSnapshot) map[string]rabbitmonit.Level {
+	levels := make(map[string]rabbitmonit.Level)
+	for i := range s.NodeProperties {
+		levels[s.NodeProperties[i].Entity().String()] = s.NodeProperties[i].Level()
+	}
+	for i := range s.VhostProperties {
+		levels[s.VhostProperties[i].Entity().String()] = s.VhostProperties[i].Level()
+	}
+	for i := range s.QueueProperties {
+		levels[s.QueueProperties[i].Entity().String()] = s.QueueProperties[i].Level()
+	}
+	return levels
+}
+
+func TestLoadSnapshotFiles(t *testing.T) {
+	full := snapshotFiles()
+	partial := snapshotFiles()
+	partial.Consumers = ""
+	missing := snapshotFiles()
+	missing.Nodes = filepath.Join("testdata", "snapshot", "missing.json")
+	malformed := snapshotFiles()
+	malformed.Queues = filepath.Join("testdata", "snapshot", "malformed.json")
+
+	tests := []struct {
+		name    string
+		files   rabbitmonit.SnapshotFiles
+		want    map[string]rabbitmonit.Level
+		wantErr string
+	}{
+		{name: "full", files: full, want: map[string]rabbitmonit.Level{
+			"node rabbit@a":  rabbitmonit.LevelWarning,
+			"node rabbit@b":  rabbitmonit.LevelOK,
+			"vhost /":        rabbitmonit.LevelWarning,
+			"queue //orders": rabbitmonit.LevelOK,
+			"queue //slow":   rabbitmonit.LevelWarning,
+			"queue //stuck":  rabbitmonit.LevelError,
+		}},
+		// without consumers.json the unacked messages alert is skipped, stuck is no longer in error
+		{name: "no consumers", files: partial, want: map[string]rabbitmonit.Level{
+			"node rabbit@a":  rabbitmonit.LevelWarning,
+			"node rabbit@b":  rabbitmonit.LevelOK,
+			"vhost /":        rabbitmonit.LevelWarning,
+			"queue //orders": rabbitmonit.LevelOK,
+			"queue //slow":   rabbitmonit.LevelWarning,
+			"queue //stuck":  rabbitmonit.LevelOK,
+		}},
+		{name: "missing file", files: missing, wantErr: "missing.json"},
+		{name: "malformed json", files: malformed, wantErr: "malformed.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := rabbitmonit.LoadSnapshotFiles(tt.files, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := snapshotLevels(s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("levels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package rabbitmonit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+QueueOverride replaces some of the queue thresholds for the queues it matches.
+
+Vhost and Queue are glob patterns (* and ?) unless Regex is set, in which case they are regular
+expressions. both kinds must match the whole name, regular expressions are anchored as ^(?:...)$.
+empty patterns match everything. Arguments, when present, must all be found with the same value in
+the queue arguments (eg. x-queue-type: quorum).
+
+LoadThresholds and Thresholds.Compile validate and compile the patterns up front. overrides built in
+code without Compile get their patterns compiled once on first use, shared by all the overrides with
+the same pattern, and an invalid pattern then panics like regexp.MustCompile
+*/
+type QueueOverride struct {
+	Name       string                  `json:"name" yaml:"name"`   // label of the rule, reported on the matched queues
+	Vhost      string                  `json:"vhost" yaml:"vhost"` // pattern for the vhost name
+	Queue      string                  `json:"queue" yaml:"queue"` // pattern for the queue name
+	Regex      bool                    `json:"regex" yaml:"regex"` // treat the patterns as regular expressions
+	Arguments  map[string]interface{}  `json:"arguments" yaml:"arguments"`
+	Thresholds QueueThresholdsOverride `json:"thresholds" yaml:"thresholds"`
+
+	vhostRe *regexp.Regexp
+	queueRe *regexp.Regexp
+}
+
+/*
+QueueThresholdsOverride holds the queue thresholds an override changes. nil values keep the
+global threshold
+*/
+type QueueThresholdsOverride struct {
+	RdyWarning            *int     `json:"rdy_warning" yaml:"rdy_warning"`
+	RdyError              *int     `json:"rdy_error" yaml:"rdy_error"`
+	ListenerWarning       *int     `json:"listener_warning" yaml:"listener_warning"`
+	ListenerError         *int     `json:"listener_error" yaml:"listener_error"`
+	UtilisationWarning    *float64 `json:"utilisation_warning" yaml:"utilisation_warning"`
+	UtilisationError      *float64 `json:"utilisation_error" yaml:"utilisation_error"`
+	IntakeRate            *float64 `json:"intake_rate" yaml:"intake_rate"`
+	ConsumptionLowWarning *float64 `json:"consumption_low_warning" yaml:"consumption_low_warning"`
+	ConsumptionLowError   *float64 `json:"consumption_low_error" yaml:"consumption_low_error"`
+}
+
+/*
+Apply returns th with the overridden values replaced
+*/
+func (o QueueThresholdsOverride) Apply(th QueueThresholds) QueueThresholds {
+	setInt := func(dst *int, src *int) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setFloat := func(dst *float64, src *float64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	setInt(&th.RdyWarning, o.RdyWarning)
+	setInt(&th.RdyError, o.RdyError)
+	setInt(&th.ListenerWarning, o.ListenerWarning)
+	setInt(&th.ListenerError, o.ListenerError)
+	setFloat(&th.UtilisationWarning, o.UtilisationWarning)
+	setFloat(&th.UtilisationError, o.UtilisationError)
+	setFloat(&th.IntakeRate, o.IntakeRate)
+	setFloat(&th.ConsumptionLowWarning, o.ConsumptionLowWarning)
+	setFloat(&th.ConsumptionLowError, o.ConsumptionLowError)
+	return th
+}
+
+/*
+Compile validates and prepares the patterns of the override. it is called by LoadThresholds
+*/
+func (o *QueueOverride) Compile() error {
+	vhostRe, err := o.pattern(o.Vhost)
+	if err != nil {
+		return fmt.Errorf("rabbitmonit: override %q vhost pattern: %v", o.Name, err)
+	}
+	queueRe, err := o.pattern(o.Queue)
+	if err != nil {
+		return fmt.Errorf("rabbitmonit: override %q queue pattern: %v", o.Name, err)
+	}
+
+	o.vhostRe, o.queueRe = vhostRe, queueRe
+	return nil
+}
+
+/*
+Matches reports whether the override applies to the given queue
+*/
+func (o *QueueOverride) Matches(queue rabbithole.QueueInfo) bool {
+	if !o.compiled(o.vhostRe, o.Vhost).MatchString(queue.Vhost) || !o.compiled(o.queueRe, o.Queue).MatchString(queue.Name) {
+		return false
+	}
+
+	for key, value := range o.Arguments {
+		actual, ok := queue.Arguments[key]
+		if !ok || fmt.Sprint(actual) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+patternKey identifies a compiled pattern in patternCache
+*/
+type patternKey struct {
+	regex   bool
+	pattern string
+}
+
+/*
+patternCache holds the patterns compiled on first use by the overrides that were not compiled
+*/
+var patternCache sync.Map
+
+/*
+compiled returns re when Compile set it, otherwise the cached compilation of p
+*/
+func (o *QueueOverride) compiled(re *regexp.Regexp, p string) *regexp.Regexp {
+	if re != nil {
+		return re
+	}
+
+	key := patternKey{o.Regex, p}
+	if cached, ok := patternCache.Load(key); ok {
+		return cached.(*regexp.Regexp)
+	}
+	re, err := o.pattern(p)
+	if err != nil {
+		panic(fmt.Sprintf("rabbitmonit: override %q was not compiled and its pattern %q is invalid: %v", o.Name, p, err))
+	}
+	patternCache.Store(key, re)
+	return re
+}
+
+/*
+pattern compiles a glob or regular expression into an anchored regexp
+*/
+func (o *QueueOverride) pattern(p string) (*regexp.Regexp, error) {
+	if p == "" {
+		return regexp.Compile(".*")
+	}
+	if o.Regex {
+		return regexp.Compile("^(?:" + p + ")$")
+	}
+	return regexp.Compile(globToRegexp(p))
+}
+
+/*
+globToRegexp converts a glob where * matches any run of characters and ? a single character
+into an anchored regular expression. unlike path.Match, * also crosses "/" so it works with vhost names
+*/
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+/*
+resolveQueueOverride returns the first override matching the queue, nil if none does
+*/
+func resolveQueueOverride(overrides []QueueOverride, queue rabbithole.QueueInfo) *QueueOverride {
+	for i := range overrides {
+		if overrides[i].Matches(queue) {
+			return &overrides[i]
+		}
+	}
+	return nil
+}
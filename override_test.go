@@ -0,0 +1,83 @@
+package rabbitmonit_test
+
+import (
+	"testing"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+func TestQueueOverrideMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		override rabbitmonit.QueueOverride
+		vhost    string
+		queue    string
+		want     bool
+	}{
+		{"empty patterns", rabbitmonit.QueueOverride{}, "/", "orders", true},
+		{"glob", rabbitmonit.QueueOverride{Queue: "*.dlq"}, "/", "orders.dlq", true},
+		{"glob is anchored", rabbitmonit.QueueOverride{Queue: "*.dlq"}, "/", "orders.dlq.old", false},
+		{"glob crosses slashes", rabbitmonit.QueueOverride{Vhost: "/billing*"}, "/billing/eu", "orders", true},
+		{"glob question mark", rabbitmonit.QueueOverride{Queue: "shard-?"}, "/", "shard-10", false},
+		{"regex", rabbitmonit.QueueOverride{Queue: `audit\..*`, Regex: true}, "/", "audit.log", true},
+		{"regex is anchored at the start", rabbitmonit.QueueOverride{Queue: `audit`, Regex: true}, "/", "pre.audit", false},
+		{"regex is anchored at the end", rabbitmonit.QueueOverride{Queue: `audit`, Regex: true}, "/", "audit.log", false},
+		{"regex alternation is anchored", rabbitmonit.QueueOverride{Queue: `audit|orders`, Regex: true}, "/", "orders.dlq", false},
+		{"explicit anchors still work", rabbitmonit.QueueOverride{Queue: `^audit\..*$`, Regex: true}, "/", "audit.log", true},
+		{"arguments", rabbitmonit.QueueOverride{Arguments: map[string]interface{}{"x-queue-type": "quorum"}}, "/", "orders", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.override
+			if err := o.Compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := o.Matches(rabbithole.QueueInfo{Vhost: tt.vhost, Name: tt.queue}); got != tt.want {
+				t.Errorf("Matches(%s, %s) = %v, want %v", tt.vhost, tt.queue, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueOverrideCompile(t *testing.T) {
+	o := rabbitmonit.QueueOverride{Name: "broken", Queue: "audit(", Regex: true}
+	if err := o.Compile(); err == nil {
+		t.Error("Compile accepted an invalid regular expression")
+	}
+
+}
+
+func TestQueueOverrideUncompiled(t *testing.T) {
+	queue := rabbithole.QueueInfo{Vhost: "/billing", Name: "audit.log"}
+
+	glob := rabbitmonit.QueueOverride{Vhost: "/billing", Queue: "audit.*"}
+	if !glob.Matches(queue) {
+		t.Error("an uncompiled glob override did not match")
+	}
+	regex := rabbitmonit.QueueOverride{Queue: `audit\.(log|trail)`, Regex: true}
+	if !regex.Matches(queue) || regex.Matches(rabbithole.QueueInfo{Vhost: "/", Name: "pre.audit.log"}) {
+		t.Error("an uncompiled regex override does not match like a compiled one")
+	}
+
+	th := &rabbitmonit.Thresholds{QueueOverrides: []rabbitmonit.QueueOverride{
+		{Name: "audit", Queue: "audit.*", Thresholds: rabbitmonit.QueueThresholdsOverride{RdyError: intPtr(50000)}},
+	}}
+	queues := rabbitmonit.EvaluateQueues([]rabbithole.QueueInfo{queue}, nil, th)
+	if queues[0].Override == nil || queues[0].Override.Name != "audit" {
+		t.Errorf("override = %v, want the uncompiled audit override applied", queues[0].Override)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("an uncompiled override with an invalid pattern did not panic")
+		}
+	}()
+	broken := rabbitmonit.QueueOverride{Name: "broken", Queue: "audit(", Regex: true}
+	broken.Matches(queue)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -1,6 +1,8 @@
 package rabbitmonit
 
 import (
+	"context"
+	"errors"
 	"strconv"
 
 	"github.com/c-datculescu/rabbit-hole"
@@ -11,11 +13,24 @@ QueueProperties offers a broader set of operations than rabbithole.QueueInfo inc
 errors and statistics
 */
 type QueueProperties struct {
-	Stats     QueueStat
-	Error     QueueAlert
-	Warning   QueueAlert
-	QueueInfo rabbithole.QueueInfo
-	Client    *rabbithole.Client
+	Stats      QueueStat
+	Error      QueueAlert
+	Warning    QueueAlert
+	QueueInfo  rabbithole.QueueInfo
+	Cluster    string           // name of the cluster the queue belongs to, set by Fleet
+	Thresholds *QueueThresholds // the limits used for alerting, nil means DefaultQueueThresholds
+	Overrides  []QueueOverride  // per queue overrides of Thresholds, the first matching one is used
+	Override   *QueueOverride   // the override that matched the queue during the last Calculate, if any
+	Consumers  *ConsumerIndex   // consumers shared by the queues of one poll, nil makes Calculate list them again
+
+	// Deprecated: Client is no longer set nor used. Calculate lists the consumers through the Ops the
+	// queue came from, which asks its CredentialProvider before every request
+	Client *rabbithole.Client
+
+	// effective are the thresholds resolved for the queue by the last Calculate
+	effective QueueThresholds
+	// ops is the Ops the queue was listed with, used to list the consumers when Consumers is nil
+	ops *Ops
 }
 
 /*
@@ -47,16 +62,52 @@ type QueueAlert struct {
 	Has            bool // identifies whether we have errors/warnings at all
 }
 
+/*
+Kinds returns the names of the flags that are set, Has excluded
+*/
+func (qa QueueAlert) Kinds() []string {
+	var kinds []string
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{qa.State, "state"},
+		{qa.NonDurable, "non_durable"},
+		{qa.Rdy, "rdy"},
+		{qa.Unack, "unack"},
+		{qa.Listener, "listener"},
+		{qa.Utilisation, "utilisation"},
+		{qa.Intake, "intake"},
+		{qa.ConsumptionLow, "consumption_low"},
+		{qa.NonDurableMsg, "non_durable_msg"},
+	} {
+		if flag.set {
+			kinds = append(kinds, flag.name)
+		}
+	}
+	return kinds
+}
+
 /*
 Calculate performs various additional calculations based on the details provided by the api
 for the queues, initialising and calculating the warnings, alerts and stats
 */
 func (qp *QueueProperties) Calculate() {
+	qp.CalculateContext(context.Background())
+}
+
+/*
+CalculateContext is the context aware variant of Calculate. without a Consumers index the unacked
+messages alert lists the consumers of the vhost through the Ops the queue came from, aborting once
+ctx is done. queues not listed by Ops skip the alert
+*/
+func (qp *QueueProperties) CalculateContext(ctx context.Context) {
 	qp.Stats = QueueStat{}
 	qp.Error = QueueAlert{}
 	qp.Warning = QueueAlert{}
 
-	qp.calculateStats().
+	qp.resolveThresholds().
+		calculateStats().
 		alertState().
 		alertDurable().
 		alertRdy().
@@ -64,7 +115,30 @@ func (qp *QueueProperties) Calculate() {
 		alertUtilisation().
 		alertIntake().
 		alertNonDurableMessages().
-		alertUnackMessages()
+		alertUnackMessages(ctx)
+}
+
+/*
+resolveThresholds picks the thresholds for the current queue, applying the first matching override
+*/
+func (qp *QueueProperties) resolveThresholds() *QueueProperties {
+	qp.effective = DefaultQueueThresholds()
+	if qp.Thresholds != nil {
+		qp.effective = *qp.Thresholds
+	}
+
+	qp.Override = resolveQueueOverride(qp.Overrides, qp.QueueInfo)
+	if qp.Override != nil {
+		qp.effective = qp.Override.Thresholds.Apply(qp.effective)
+	}
+	return qp
+}
+
+/*
+thresholds returns the limits resolved for the current queue
+*/
+func (qp *QueueProperties) thresholds() QueueThresholds {
+	return qp.effective
 }
 
 func (qp *QueueProperties) calculateStats() *QueueProperties {
@@ -72,11 +146,12 @@ func (qp *QueueProperties) calculateStats() *QueueProperties {
 	switch qp.QueueInfo.ConsumerUtilisation.(type) {
 	case string:
 		util, _ = strconv.ParseFloat(qp.QueueInfo.ConsumerUtilisation.(string), 64)
-	default:
+	case float64:
 		util = qp.QueueInfo.ConsumerUtilisation.(float64)
 	}
 
 	qp.Stats.Utilisation = util
+	qp.Stats.EnqueueDequeueDiff = qp.QueueInfo.MessageStats.PublishDetails.Rate - qp.QueueInfo.MessageStats.DeliverDetails.Rate
 
 	qp.Stats.RdyReduced = reduceInt(qp.QueueInfo.MessagesRdy)
 	qp.Stats.TransReduced = reduceInt(qp.Stats.NonPersistentMessagesCount)
@@ -90,15 +165,16 @@ func (qp *QueueProperties) calculateStats() *QueueProperties {
 alertRdy calculates whether it should raise an alert or warning when there are messages in the queue that are in
 ready status
 
-threshold for warnings is 0
+threshold for warnings is RdyWarning (default 0)
 
-threshold for error is 100
+threshold for error is RdyError (default 100)
 */
 func (qp *QueueProperties) alertRdy() *QueueProperties {
-	if qp.QueueInfo.MessagesRdy > 100 {
+	th := qp.thresholds()
+	if qp.QueueInfo.MessagesRdy > th.RdyError {
 		qp.Error.Rdy = true
 		qp.Error.Has = true
-	} else if qp.QueueInfo.MessagesRdy > 0 {
+	} else if qp.QueueInfo.MessagesRdy > th.RdyWarning {
 		qp.Warning.Rdy = true
 		qp.Warning.Has = true
 	}
@@ -109,15 +185,16 @@ func (qp *QueueProperties) alertRdy() *QueueProperties {
 alertListener calculates whether there should be an alert/warning raised for the number of listeners on the current
 queue
 
-threshold for alert is 0 listeners and more than 0 ready messages
+threshold for alert is ListenerError listeners (default 0) and more than 0 ready messages
 
-threshold for warning is 3 listeners and more than 0 ready messages
+threshold for warning is ListenerWarning listeners (default 3) and more than 0 ready messages
 */
 func (qp *QueueProperties) alertListener() *QueueProperties {
-	if qp.QueueInfo.Consumers == 0 && qp.QueueInfo.MessagesRdy > 0 {
+	th := qp.thresholds()
+	if qp.QueueInfo.Consumers <= th.ListenerError && qp.QueueInfo.MessagesRdy > 0 {
 		qp.Error.Listener = true
 		qp.Error.Has = true
-	} else if qp.QueueInfo.Consumers <= 3 && qp.QueueInfo.MessagesRdy > 0 {
+	} else if qp.QueueInfo.Consumers <= th.ListenerWarning && qp.QueueInfo.MessagesRdy > 0 {
 		qp.Warning.Has = true
 		qp.Warning.Listener = true
 	}
@@ -130,9 +207,9 @@ utilisation is important to give an overview of how many messages end up being d
 
 utlisation is only usable though for high traffic queues
 
-threshold for alert is utilisation less than 30 and more than 0 messages ready in the queue
+threshold for alert is utilisation less than UtilisationError (default 30) and more than 0 messages ready in the queue
 
-threshold for warning is utilisation less than 70 and messages ready in the queue
+threshold for warning is utilisation less than UtilisationWarning (default 70) and messages ready in the queue
 */
 func (qp *QueueProperties) alertUtilisation() *QueueProperties {
 	var consumerUtilisation float64
@@ -146,10 +223,11 @@ func (qp *QueueProperties) alertUtilisation() *QueueProperties {
 		consumerUtilisation = qp.QueueInfo.ConsumerUtilisation.(float64)
 	}
 
-	if consumerUtilisation < 30 && qp.QueueInfo.MessagesRdy > 0 {
+	th := qp.thresholds()
+	if consumerUtilisation < th.UtilisationError && qp.QueueInfo.MessagesRdy > 0 {
 		qp.Error.Has = true
 		qp.Error.Utilisation = true
-	} else if consumerUtilisation < 70 && qp.QueueInfo.MessagesRdy > 0 {
+	} else if consumerUtilisation < th.UtilisationWarning && qp.QueueInfo.MessagesRdy > 0 {
 		qp.Warning.Has = true
 		qp.Warning.Utilisation = true
 	}
@@ -159,10 +237,12 @@ func (qp *QueueProperties) alertUtilisation() *QueueProperties {
 /*
 alertIntake should be deprecated and replaced by a diff between enqueue rate and dequeue rate
 
+threshold is a ready messages growth rate above IntakeRate (default 1)
+
 @todo replace intake alert with enqueue/dequeue rate difference
 */
 func (qp *QueueProperties) alertIntake() *QueueProperties {
-	if qp.QueueInfo.MessagesRdyDetails.Rate > 1 {
+	if float64(qp.QueueInfo.MessagesRdyDetails.Rate) > qp.thresholds().IntakeRate {
 		qp.Error.Has = true
 		qp.Warning.Intake = true
 	}
@@ -186,20 +266,21 @@ func (qp *QueueProperties) alertNonDurableMessages() *QueueProperties {
 /*
 alertUnackMessages raises an alert by analysing individual properties of consumers on queue as well as unack messages
 
+the consumers come from the shared Consumers index when present, otherwise they are listed for the vhost of the queue
+
 the threshold for alert is sum of consumer prefetch count is lower than the number of unack messages in the queue
 */
-func (qp *QueueProperties) alertUnackMessages() *QueueProperties {
-	consumers, err := qp.Client.ConsumersIn(qp.QueueInfo.Vhost)
-	if err != nil {
-		return qp
-	}
-	var total int
-	for _, consumer := range consumers {
-		if qp.QueueInfo.Name == consumer.Queue.Name && qp.QueueInfo.Vhost == consumer.Queue.Vhost {
-			total += consumer.PrefetchCount
+func (qp *QueueProperties) alertUnackMessages(ctx context.Context) *QueueProperties {
+	consumers := qp.Consumers
+	if consumers == nil {
+		listed, err := qp.consumers(ctx)
+		if err != nil {
+			return qp
 		}
+		consumers = NewConsumerIndex(listed)
 	}
 
+	total := consumers.PrefetchTotal(qp.QueueInfo.Vhost, qp.QueueInfo.Name)
 	if qp.QueueInfo.MessagesUnack > total {
 		qp.Error.Has = true
 		qp.Error.Unack = true
@@ -207,6 +288,16 @@ func (qp *QueueProperties) alertUnackMessages() *QueueProperties {
 	return qp
 }
 
+/*
+consumers lists the consumers in the vhost of the queue through the Ops the queue came from
+*/
+func (qp *QueueProperties) consumers(ctx context.Context) ([]rabbithole.ConsumerInfo, error) {
+	if qp.ops == nil {
+		return nil, errors.New("rabbitmonit: no Ops available for the consumers lookup")
+	}
+	return qp.ops.consumerInfosIn(ctx, qp.QueueInfo.Vhost)
+}
+
 /*
 alertState raises an alert if the state of the queue is not "running"
 */
@@ -232,18 +323,18 @@ func (qp *QueueProperties) alertDurable() *QueueProperties {
 /*
 alertConsumptionLow raises an alert/warning when consumption rate vs ingestion rate exceeds certain values
 
-threshold for alert is rate difference bigger than 10 and consuption is less than publishing
+threshold for alert is rate difference bigger than ConsumptionLowError (default 10) and consuption is less than publishing
 
-threshold for alert is rate difference bigger than 5 and consuption is less than publishing
+threshold for warning is rate difference bigger than ConsumptionLowWarning (default 5) and consuption is less than publishing
 */
 func (qp *QueueProperties) alertConsumptionLow() *QueueProperties {
-	rate := qp.QueueInfo.MessageStats.PublishDetails.Rate - qp.QueueInfo.MessageStats.DeliverDetails.Rate
-	qp.Stats.EnqueueDequeueDiff = rate
+	rate := qp.Stats.EnqueueDequeueDiff
 	lowerThan := qp.QueueInfo.MessageStats.DeliverDetails.Rate < qp.QueueInfo.MessageStats.PublishDetails.Rate
-	if lowerThan && rate > 10 {
+	th := qp.thresholds()
+	if lowerThan && float64(rate) > th.ConsumptionLowError {
 		qp.Error.Has = true
 		qp.Error.ConsumptionLow = true
-	} else if lowerThan && rate > 5 {
+	} else if lowerThan && float64(rate) > th.ConsumptionLowWarning {
 		qp.Warning.Has = true
 		qp.Warning.ConsumptionLow = true
 	}
@@ -0,0 +1,59 @@
+package rabbitmonit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+/*
+TestCalculateContextConsumers checks that a queue without a Consumers index lists them again through
+its Ops, and that the lookup is aborted with ctx
+*/
+func TestCalculateContextConsumers(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{
+		Queues: []rabbithole.QueueInfo{
+			{Name: "orders", Vhost: "/", State: "running", Durable: true, MessagesUnack: 50},
+		},
+		Consumers: []rabbithole.ConsumerInfo{
+			{ConsumerTag: "c1", PrefetchCount: 10, Queue: rabbithole.QueueDetail{Name: "orders", Vhost: "/"}},
+		},
+	})
+	defer srv.Close()
+
+	queues, err := srv.Ops().ListAccumulationQueues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	qp := queues[0]
+	qp.Consumers = nil
+
+	srv.Fail("/api/consumers/%2F", fakeapi.Failure{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	qp.CalculateContext(ctx)
+	if elapsed := time.Since(started); elapsed >= time.Second {
+		t.Errorf("CalculateContext took %s, want it aborted with ctx", elapsed)
+	}
+	if qp.Error.Unack {
+		t.Error("unack raised although the consumers lookup timed out")
+	}
+
+	srv.Recover("/api/consumers/%2F")
+	qp.CalculateContext(context.Background())
+	if !qp.Error.Unack {
+		t.Error("unack not raised, want 50 unacked messages over a prefetch of 10")
+	}
+
+	var detached rabbitmonit.QueueProperties
+	detached.QueueInfo = qp.QueueInfo
+	detached.Calculate()
+	if detached.Error.Unack {
+		t.Error("unack raised for a queue without Consumers nor Ops")
+	}
+}
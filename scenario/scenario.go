@@ -0,0 +1,297 @@
+/*
+Package scenario runs the golden alerting scenarios.
+
+a scenario is a yaml file holding management api payloads (queues, consumers, nodes, vhosts, exchanges,
+bindings, connections and channels, using the
+json field names of the api) and the alert flags every entity is expected to end up with. threshold
+changes are reviewed as diffs of these files and verified by `go test ./scenario`
+*/
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+Scenario is one golden fixture
+*/
+type Scenario struct {
+	Name        string
+	Path        string
+	Description string
+	Thresholds  *rabbitmonit.Thresholds // nil means the default thresholds
+	Queues      []rabbithole.QueueInfo
+	Consumers   []rabbithole.ConsumerInfo
+	Nodes       []rabbithole.NodeInfo
+	Vhosts      []rabbithole.VhostInfo
+	Exchanges   []rabbitmonit.ExchangeInfo
+	Bindings    []rabbithole.BindingInfo
+	Connections []rabbitmonit.ConnectionInfo
+	Now         time.Time // reference time for the connection ages, the time of the run when not set
+	Channels    []rabbitmonit.ChannelInfo
+	Polls       int // number of polls returning the same channels, for the saturated alert. defaults to 1
+	Expect      Expect
+}
+
+/*
+Expect lists the expected result of every entity of the scenario. entities missing from it are expected
+to raise nothing
+*/
+type Expect struct {
+	Queues      []Expected `json:"queues"`
+	Nodes       []Expected `json:"nodes"`
+	Vhosts      []Expected `json:"vhosts"`
+	Exchanges   []Expected `json:"exchanges"`
+	Connections []Expected `json:"connections"`
+	Channels    []Expected `json:"channels"`
+	Consumers   []Expected `json:"consumers"` // identified by Vhost and Name, the name being "<queue> <consumer tag>"
+}
+
+/*
+Expected is the expected outcome for one entity, identified by Name (and Vhost for queues and exchanges)
+*/
+type Expected struct {
+	Vhost    string   `json:"vhost"`
+	Name     string   `json:"name"`
+	Level    string   `json:"level"` // optional, ok, warning or error
+	Error    []string `json:"error"`
+	Warning  []string `json:"warning"`
+	Override string   `json:"override"` // optional, name of the queue override expected to match
+}
+
+/*
+Load reads a scenario file
+*/
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	fields, ok := toJSONCompatible(doc).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: not a yaml mapping", path)
+	}
+
+	s := &Scenario{Path: path}
+	s.Name, _ = fields["name"].(string)
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	s.Description, _ = fields["description"].(string)
+
+	if raw, ok := fields["thresholds"]; ok {
+		th := rabbitmonit.DefaultThresholds()
+		if err := convert(raw, &th); err != nil {
+			return nil, fmt.Errorf("%s: thresholds: %v", path, err)
+		}
+		if err := th.Compile(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		s.Thresholds = &th
+	}
+
+	for key, out := range map[string]interface{}{
+		"queues":      &s.Queues,
+		"consumers":   &s.Consumers,
+		"nodes":       &s.Nodes,
+		"vhosts":      &s.Vhosts,
+		"exchanges":   &s.Exchanges,
+		"bindings":    &s.Bindings,
+		"connections": &s.Connections,
+		"now":         &s.Now,
+		"channels":    &s.Channels,
+		"polls":       &s.Polls,
+		"expect":      &s.Expect,
+	} {
+		if raw, ok := fields[key]; ok {
+			if err := convert(raw, out); err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", path, key, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+/*
+LoadDir reads all the .yml/.yaml scenarios of a directory, sorted by file name
+*/
+func LoadDir(dir string) ([]*Scenario, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var scenarios []*Scenario
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		s, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, s)
+	}
+	return scenarios, nil
+}
+
+/*
+Result is what a single entity ended up with
+*/
+type Result struct {
+	Level    string
+	Error    []string
+	Warning  []string
+	Override string
+}
+
+/*
+Run evaluates the scenario and returns a description of every difference with the expectations,
+nothing when the scenario passes
+*/
+func (s *Scenario) Run() []string {
+	actual := make(map[string]Result)
+
+	for _, qp := range rabbitmonit.EvaluateQueues(s.Queues, rabbitmonit.NewConsumerIndex(s.Consumers), s.Thresholds) {
+		r := Result{Level: qp.Level().String(), Error: qp.Error.Kinds(), Warning: qp.Warning.Kinds()}
+		if qp.Override != nil {
+			r.Override = qp.Override.Name
+		}
+		actual[key("queue", qp.QueueInfo.Vhost, qp.QueueInfo.Name)] = r
+	}
+	for _, np := range rabbitmonit.EvaluateNodes(s.Nodes, s.Thresholds) {
+		actual[key("node", "", np.NodeInfo.Name)] = Result{Level: np.Level().String(), Error: np.Error.Kinds(), Warning: np.Warning.Kinds()}
+	}
+	for _, vp := range rabbitmonit.EvaluateVhosts(s.Vhosts, s.Thresholds) {
+		actual[key("vhost", "", vp.VhostInfo.Name)] = Result{Level: vp.Level().String(), Error: vp.Error.Kinds(), Warning: vp.Warning.Kinds()}
+	}
+	for _, ep := range rabbitmonit.EvaluateExchanges(s.Exchanges, s.Bindings, s.Thresholds) {
+		actual[key("exchange", ep.ExchangeInfo.Vhost, ep.ExchangeInfo.Name)] = Result{Level: ep.Level().String(), Error: ep.Error.Kinds(), Warning: ep.Warning.Kinds()}
+	}
+	now := s.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for _, cp := range rabbitmonit.EvaluateConnections(s.Connections, now, s.Thresholds) {
+		actual[key("connection", "", cp.ConnectionInfo.Name)] = Result{Level: cp.Level().String(), Error: cp.Error.Kinds(), Warning: cp.Warning.Kinds()}
+	}
+	tracker := rabbitmonit.NewChannelTracker()
+	var channels []rabbitmonit.ChannelProperties
+	for poll := 0; poll < s.Polls || poll == 0; poll++ {
+		channels = tracker.Track(rabbitmonit.EvaluateChannels(s.Channels, s.Thresholds))
+	}
+	for _, cp := range channels {
+		actual[key("channel", "", cp.ChannelInfo.Name)] = Result{Level: cp.Level().String(), Error: cp.Error.Kinds(), Warning: cp.Warning.Kinds()}
+	}
+	for _, cp := range rabbitmonit.EvaluateConsumers(s.Consumers, s.Queues, s.Thresholds) {
+		info := cp.ConsumerInfo
+		actual[key("consumer", info.Queue.Vhost, info.Queue.Name+" "+info.ConsumerTag)] = Result{Level: cp.Level().String(), Error: cp.Error.Kinds(), Warning: cp.Warning.Kinds()}
+	}
+
+	expected := make(map[string]Expected)
+	for kind, list := range map[string][]Expected{"queue": s.Expect.Queues, "node": s.Expect.Nodes, "vhost": s.Expect.Vhosts, "exchange": s.Expect.Exchanges, "connection": s.Expect.Connections, "channel": s.Expect.Channels, "consumer": s.Expect.Consumers} {
+		for _, e := range list {
+			vhost := e.Vhost
+			if kind != "queue" && kind != "exchange" && kind != "consumer" {
+				vhost = ""
+			}
+			expected[key(kind, vhost, e.Name)] = e
+		}
+	}
+
+	var diffs []string
+	for k := range expected {
+		if _, ok := actual[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: expected but not part of the payloads", k))
+		}
+	}
+
+	for k, r := range actual {
+		e := expected[k]
+		if e.Level != "" && e.Level != r.Level {
+			diffs = append(diffs, fmt.Sprintf("%s: level %s, expected %s", k, r.Level, e.Level))
+		}
+		if !sameKinds(r.Error, e.Error) {
+			diffs = append(diffs, fmt.Sprintf("%s: error %v, expected %v", k, r.Error, e.Error))
+		}
+		if !sameKinds(r.Warning, e.Warning) {
+			diffs = append(diffs, fmt.Sprintf("%s: warning %v, expected %v", k, r.Warning, e.Warning))
+		}
+		if e.Override != r.Override {
+			diffs = append(diffs, fmt.Sprintf("%s: override %q, expected %q", k, r.Override, e.Override))
+		}
+	}
+
+	sort.Strings(diffs)
+	return diffs
+}
+
+func key(kind, vhost, name string) string {
+	if kind == "queue" || kind == "exchange" || kind == "consumer" {
+		return kind + " " + vhost + "/" + name
+	}
+	return kind + " " + name
+}
+
+func sameKinds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+convert decodes a generic yaml value into out through its json representation, so the json tags of
+the rabbithole types apply
+*/
+func convert(in interface{}, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+/*
+toJSONCompatible turns the map[interface{}]interface{} produced by yaml.v2 into map[string]interface{}
+*/
+func toJSONCompatible(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprint(key)] = toJSONCompatible(value)
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = toJSONCompatible(v[i])
+		}
+		return v
+	}
+	return in
+}
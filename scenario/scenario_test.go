@@ -0,0 +1,25 @@
+package scenario
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestScenarios(t *testing.T) {
+	scenarios, err := LoadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no scenario found in testdata")
+	}
+
+	for _, s := range scenarios {
+		s := s
+		t.Run(filepath.Base(s.Path), func(t *testing.T) {
+			for _, diff := range s.Run() {
+				t.Error(diff)
+			}
+		})
+	}
+}
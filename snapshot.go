@@ -0,0 +1,109 @@
+package rabbitmonit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+ClusterSnapshot holds everything gathered during one poll of a cluster. the raw management api data
+is what gets serialised, the properties are computed again by Evaluate so an archived snapshot can be
+re-evaluated with different thresholds
+*/
+type ClusterSnapshot struct {
+	Timestamp   time.Time                 `json:"timestamp"`
+	Overview    OverviewInfo              `json:"overview"`
+	Nodes       []rabbithole.NodeInfo     `json:"nodes"`
+	Vhosts      []rabbithole.VhostInfo    `json:"vhosts"`
+	Queues      []rabbithole.QueueInfo    `json:"queues"`
+	Consumers   []rabbithole.ConsumerInfo `json:"consumers"`
+	Connections []ConnectionInfo          `json:"connections"`
+	Channels    []ChannelInfo             `json:"channels"`
+
+	NodeProperties       []NodeProperties       `json:"-"`
+	VhostProperties      []VhostProperties      `json:"-"`
+	QueueProperties      []QueueProperties      `json:"-"`
+	ConnectionProperties []ConnectionProperties `json:"-"`
+	ChannelProperties    []ChannelProperties    `json:"-"`
+	ConsumerProperties   []ConsumerProperties   `json:"-"`
+}
+
+/*
+Snapshot gathers a ClusterSnapshot and evaluates it with the thresholds of Ops
+*/
+func (p *Ops) Snapshot() (*ClusterSnapshot, error) {
+	return p.SnapshotContext(context.Background())
+}
+
+/*
+SnapshotContext is like Snapshot but aborts the management api calls once ctx is done
+*/
+func (p *Ops) SnapshotContext(ctx context.Context) (*ClusterSnapshot, error) {
+	s := &ClusterSnapshot{Timestamp: time.Now()}
+
+	for _, part := range []struct {
+		path string
+		out  interface{}
+	}{
+		{"overview", &s.Overview},
+		{"nodes", &s.Nodes},
+		{"vhosts", &s.Vhosts},
+		{"queues", &s.Queues},
+		{"consumers", &s.Consumers},
+		{"connections", &s.Connections},
+		{"channels", &s.Channels},
+	} {
+		if err := p.get(ctx, "Snapshot", part.path, part.out); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Evaluate(p.Thresholds)
+	return s, nil
+}
+
+/*
+Evaluate computes the node, vhost, queue, connection, channel and consumer properties against the data
+of the snapshot, connection ages being measured up to Timestamp. th may be nil for the default thresholds.
+
+a snapshot without consumers (nil, as opposed to an empty list) skips the unacked messages alert of the
+queues instead of raising it for every queue holding unacked messages
+*/
+func (s *ClusterSnapshot) Evaluate(th *Thresholds) {
+	var consumers *ConsumerIndex
+	if s.Consumers != nil {
+		consumers = NewConsumerIndex(s.Consumers)
+	}
+
+	s.NodeProperties = EvaluateNodes(s.Nodes, th)
+	s.VhostProperties = EvaluateVhosts(s.Vhosts, th)
+	s.QueueProperties = EvaluateQueues(s.Queues, consumers, th)
+	s.ConnectionProperties = EvaluateConnections(s.Connections, s.Timestamp, th)
+	s.ChannelProperties = EvaluateChannels(s.Channels, th)
+	s.ConsumerProperties = EvaluateConsumers(s.Consumers, s.Queues, th)
+}
+
+/*
+WriteJSON archives the raw data of the snapshot
+*/
+func (s *ClusterSnapshot) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+/*
+ReadSnapshot loads a snapshot written by WriteJSON and evaluates it with th
+*/
+func ReadSnapshot(r io.Reader, th *Thresholds) (*ClusterSnapshot, error) {
+	s := &ClusterSnapshot{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	s.Evaluate(th)
+	return s, nil
+}
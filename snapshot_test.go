@@ -0,0 +1,39 @@
+package rabbitmonit_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-monit"
+)
+
+func TestSnapshotWithoutConsumers(t *testing.T) {
+	const queues = `"queues": [{"name": "orders", "vhost": "/", "state": "running", "durable": true, "messages_unacknowledged": 50}]`
+
+	tests := []struct {
+		name      string
+		json      string
+		wantUnack bool
+	}{
+		{"consumers missing", `{` + queues + `}`, false},
+		{"consumers null", `{` + queues + `, "consumers": null}`, false},
+		{"no consumer", `{` + queues + `, "consumers": []}`, true},
+		{"prefetch too low", `{` + queues + `, "consumers": [{"consumer_tag": "c1", "prefetch_count": 10, "queue": {"name": "orders", "vhost": "/"}}]}`, true},
+		{"prefetch high enough", `{` + queues + `, "consumers": [{"consumer_tag": "c1", "prefetch_count": 100, "queue": {"name": "orders", "vhost": "/"}}]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := rabbitmonit.ReadSnapshot(strings.NewReader(tt.json), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.QueueProperties) != 1 {
+				t.Fatalf("got %d queues, want 1", len(s.QueueProperties))
+			}
+			if got := s.QueueProperties[0].Error.Unack; got != tt.wantUnack {
+				t.Errorf("unack = %v, want %v", got, tt.wantUnack)
+			}
+		})
+	}
+}
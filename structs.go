@@ -1,7 +1,11 @@
 package rabbitmonit
 
 import (
+	"context"
+	"net/http"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/c-datculescu/rabbit-hole"
 )
@@ -15,158 +19,393 @@ type Ops struct {
 	Host     string // the host to connect including the port
 	Login    string // the username that allows us to retrieve statistics
 	Password string // password for the username
+
+	Credentials CredentialProvider // when set, replaces Login and Password for the management api requests
+
+	Thresholds *Thresholds // the limits used for alerting, nil means DefaultThresholds
+
+	// connection settings, read once on the first call. see client.go
+	Timeout      time.Duration   // upper bound for every management api request, 0 means no limit
+	MaxIdleConns int             // keep-alive connections kept per host, defaults to 10
+	UserAgent    string          // defaults to "rabbit-monit"
+	Transport    *http.Transport // replaces the default transport, MaxIdleConns is then ignored
+	TLS          *TLSConfig      // tls settings for https endpoints, applied on top of Transport
+	HTTPClient   *http.Client    // replaces the whole http client, eg. for tests. the other settings are ignored
+
+	setup      sync.Once
+	httpClient *http.Client
+	setupErr   error
 }
 
 /*
-client returns a *rabbithole.Client on which we can run various operation types
+ListVhosts returns a list of all vhosts in the current cluster and sorts them by warnings/errors
 */
-func (p *Ops) client() *rabbithole.Client {
-	client, err := rabbithole.NewClient(p.Host, p.Login, p.Password)
-	if err != nil {
-		panic(err.Error())
+func (p *Ops) ListVhosts() ([]VhostProperties, error) {
+	return p.ListVhostsContext(context.Background())
+}
+
+/*
+ListVhostsContext is like ListVhosts but aborts the management api calls once ctx is done
+*/
+func (p *Ops) ListVhostsContext(ctx context.Context) ([]VhostProperties, error) {
+	var vhostsRet []rabbithole.VhostInfo
+	if err := p.get(ctx, "ListVhosts", "vhosts", &vhostsRet); err != nil {
+		return nil, err
 	}
 
-	return client
+	return EvaluateVhosts(vhostsRet, p.Thresholds), nil
 }
 
 /*
-Vhosts returns a list of all vhosts in the current cluster and sorts them by warnings/errors
+GetVhost is a small indirection which returns a vhost
 */
-func (p *Ops) Vhosts() []VhostProperties {
-	client := p.client()
-	vhostsRet, err := client.ListVhosts()
+func (p *Ops) GetVhost(vhost string) (rabbithole.VhostInfo, error) {
+	return p.GetVhostContext(context.Background(), vhost)
+}
 
-	if err != nil {
-		panic(err.Error())
+/*
+GetVhostContext is like GetVhost but aborts the management api calls once ctx is done
+*/
+func (p *Ops) GetVhostContext(ctx context.Context, vhost string) (rabbithole.VhostInfo, error) {
+	var vhostRet rabbithole.VhostInfo
+	if err := p.get(ctx, "GetVhost", "vhosts/"+apiPath(vhost), &vhostRet); err != nil {
+		return vhostRet, err
 	}
 
-	var mapVhosts []VhostProperties
+	return vhostRet, nil
+}
+
+/*
+ListNodes returns information about the current cluster individual nodes status
+*/
+func (p *Ops) ListNodes() ([]NodeProperties, error) {
+	return p.ListNodesContext(context.Background())
+}
 
-	for _, vhost := range vhostsRet {
-		vh := &VhostProperties{
-			VhostInfo: vhost,
-		}
-		vh.Calculate()
-		mapVhosts = append(mapVhosts, *vh)
+/*
+ListNodesContext is like ListNodes but aborts the management api calls once ctx is done
+*/
+func (p *Ops) ListNodesContext(ctx context.Context) ([]NodeProperties, error) {
+	var nodes []rabbithole.NodeInfo
+	if err := p.get(ctx, "ListNodes", "nodes", &nodes); err != nil {
+		return nil, err
 	}
 
-	vs := &vhostSorter{}
-	vs.Sort(mapVhosts)
+	return EvaluateNodes(nodes, p.Thresholds), nil
+}
 
-	return mapVhosts
+/*
+ListAccumulationQueues returns the most offending queues which can be a risk for the
+cluster health
+*/
+func (p *Ops) ListAccumulationQueues() ([]QueueProperties, error) {
+	return p.ListAccumulationQueuesContext(context.Background())
 }
 
 /*
-Vhost is a small indirection which returns a vhost
+ListAccumulationQueuesContext is like ListAccumulationQueues but aborts the management api calls once ctx is done
 */
-func (p *Ops) Vhost(vhost string) rabbithole.VhostInfo {
-	client := p.client()
-	vhostRet, err := client.GetVhost(vhost)
+func (p *Ops) ListAccumulationQueuesContext(ctx context.Context) ([]QueueProperties, error) {
+	var queues []rabbithole.QueueInfo
+	if err := p.get(ctx, "ListAccumulationQueues", "queues", &queues); err != nil {
+		return nil, err
+	}
 
+	consumers, err := p.consumerInfos(ctx)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
-	return *vhostRet
+	return p.queueProperties(queues, NewConsumerIndex(consumers)), nil
 }
 
 /*
-Nodes returns information about the current cluster individual nodes status
+GetQueue returns details about a queue from the api
+*/
+func (p *Ops) GetQueue(vhost, queue string) (QueueProperties, error) {
+	return p.GetQueueContext(context.Background(), vhost, queue)
+}
+
+/*
+GetQueueContext is like GetQueue but aborts the management api calls once ctx is done
 */
-func (p *Ops) Nodes() (returnNodes []NodeProperties) {
-	client := p.client()
+func (p *Ops) GetQueueContext(ctx context.Context, vhost, queue string) (QueueProperties, error) {
+	var queueDetail rabbithole.QueueInfo
+	if err := p.get(ctx, "GetQueue", "queues/"+apiPath(vhost, queue), &queueDetail); err != nil {
+		return QueueProperties{}, err
+	}
 
-	nodes, err := client.ListNodes()
+	consumers, err := p.consumerInfosIn(ctx, vhost)
 	if err != nil {
-		panic(err.Error())
+		return QueueProperties{}, err
 	}
 
-	for _, node := range nodes {
-		localNode := NodeProperties{
-			NodeInfo: node,
-		}
+	retQueue := &QueueProperties{
+		QueueInfo:  queueDetail,
+		Thresholds: p.Thresholds.queue(),
+		Overrides:  p.Thresholds.queueOverrides(),
+		Consumers:  NewConsumerIndex(consumers),
+		ops:        p,
+	}
+
+	retQueue.Calculate()
+	return *retQueue, nil
+}
+
+/*
+ListQueues returns all the queues from a vhost along with detailed information about them
+*/
+func (p *Ops) ListQueues(vhost string) ([]QueueProperties, error) {
+	return p.ListQueuesContext(context.Background(), vhost)
+}
 
-		localNode.Calculate()
+/*
+ListQueuesContext is like ListQueues but aborts the management api calls once ctx is done
+*/
+func (p *Ops) ListQueuesContext(ctx context.Context, vhost string) ([]QueueProperties, error) {
+	var queues []rabbithole.QueueInfo
+	if err := p.get(ctx, "ListQueues", "queues/"+apiPath(vhost), &queues); err != nil {
+		return nil, err
+	}
 
-		returnNodes = append(returnNodes, localNode)
+	consumers, err := p.consumerInfosIn(ctx, vhost)
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	return p.queueProperties(queues, NewConsumerIndex(consumers)), nil
 }
 
 /*
-AccumulationQueues returns the top 10 most offending queues which can be a risk for the
-cluster health
+ListExchanges returns the exchanges of a vhost, or of the whole cluster when vhost is empty, sorted
+worst first
 */
-func (p *Ops) AccumulationQueues() []QueueProperties {
-	client := p.client()
+func (p *Ops) ListExchanges(vhost string) ([]ExchangeProperties, error) {
+	return p.ListExchangesContext(context.Background(), vhost)
+}
+
+/*
+ListExchangesContext is like ListExchanges but aborts the management api calls once ctx is done
+*/
+func (p *Ops) ListExchangesContext(ctx context.Context, vhost string) ([]ExchangeProperties, error) {
+	exchangesPath, bindingsPath := "exchanges", "bindings"
+	if vhost != "" {
+		exchangesPath += "/" + apiPath(vhost)
+		bindingsPath += "/" + apiPath(vhost)
+	}
+
+	var exchanges []ExchangeInfo
+	if err := p.get(ctx, "ListExchanges", exchangesPath, &exchanges); err != nil {
+		return nil, err
+	}
+
+	var bindings []rabbithole.BindingInfo
+	if err := p.get(ctx, "ListBindings", bindingsPath, &bindings); err != nil {
+		return nil, err
+	}
+
+	return EvaluateExchanges(exchanges, bindings, p.Thresholds), nil
+}
+
+/*
+ListConnections returns the client connections of a vhost, or of the whole cluster when vhost is empty,
+sorted worst first
+*/
+func (p *Ops) ListConnections(vhost string) ([]ConnectionProperties, error) {
+	return p.ListConnectionsContext(context.Background(), vhost)
+}
+
+/*
+ListConnectionsContext is like ListConnections but aborts the management api calls once ctx is done
+*/
+func (p *Ops) ListConnectionsContext(ctx context.Context, vhost string) ([]ConnectionProperties, error) {
+	path := "connections"
+	if vhost != "" {
+		path = "vhosts/" + apiPath(vhost) + "/connections"
+	}
+
+	var connections []ConnectionInfo
+	if err := p.get(ctx, "ListConnections", path, &connections); err != nil {
+		return nil, err
+	}
+
+	return EvaluateConnections(connections, time.Now(), p.Thresholds), nil
+}
+
+/*
+ListChannels returns the channels of a vhost, or of the whole cluster when vhost is empty, sorted worst
+first. every call is a single poll, pass the result to a ChannelTracker for the saturated alert
+*/
+func (p *Ops) ListChannels(vhost string) ([]ChannelProperties, error) {
+	return p.ListChannelsContext(context.Background(), vhost)
+}
+
+/*
+ListChannelsContext is like ListChannels but aborts the management api calls once ctx is done
+*/
+func (p *Ops) ListChannelsContext(ctx context.Context, vhost string) ([]ChannelProperties, error) {
+	path := "channels"
+	if vhost != "" {
+		path = "vhosts/" + apiPath(vhost) + "/channels"
+	}
+
+	var channels []ChannelInfo
+	if err := p.get(ctx, "ListChannels", path, &channels); err != nil {
+		return nil, err
+	}
+
+	return EvaluateChannels(channels, p.Thresholds), nil
+}
 
-	var mapExtendedQueues []QueueProperties
+/*
+ListConsumers returns the consumers of a vhost, or of the whole cluster when vhost is empty, sorted worst
+first. see GroupConsumers for a report per queue
+*/
+func (p *Ops) ListConsumers(vhost string) ([]ConsumerProperties, error) {
+	return p.ListConsumersContext(context.Background(), vhost)
+}
+
+/*
+ListConsumersContext is like ListConsumers but aborts the management api calls once ctx is done
+*/
+func (p *Ops) ListConsumersContext(ctx context.Context, vhost string) ([]ConsumerProperties, error) {
+	queuesPath := "queues"
+	if vhost != "" {
+		queuesPath += "/" + apiPath(vhost)
+	}
 
-	queues, err := client.ListQueues()
+	var queues []rabbithole.QueueInfo
+	if err := p.get(ctx, "ListConsumers", queuesPath, &queues); err != nil {
+		return nil, err
+	}
+
+	var consumers []rabbithole.ConsumerInfo
+	var err error
+	if vhost == "" {
+		consumers, err = p.consumerInfos(ctx)
+	} else {
+		consumers, err = p.consumerInfosIn(ctx, vhost)
+	}
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
-	for _, queue := range queues {
-		extQueue := new(QueueProperties)
-		extQueue.Client = client
-		extQueue.QueueInfo = queue
-		extQueue.Calculate()
+	return EvaluateConsumers(consumers, queues, p.Thresholds), nil
+}
+
+/*
+consumerInfos returns all the consumers of the cluster
+*/
+func (p *Ops) consumerInfos(ctx context.Context) ([]rabbithole.ConsumerInfo, error) {
+	var consumers []rabbithole.ConsumerInfo
+	if err := p.get(ctx, "ListConsumers", "consumers", &consumers); err != nil {
+		return nil, err
+	}
+	return consumers, nil
+}
 
-		mapExtendedQueues = append(mapExtendedQueues, *extQueue)
+/*
+consumerInfosIn returns all the consumers registered in the given vhost
+*/
+func (p *Ops) consumerInfosIn(ctx context.Context, vhost string) ([]rabbithole.ConsumerInfo, error) {
+	var consumers []rabbithole.ConsumerInfo
+	if err := p.get(ctx, "ListConsumers", "consumers/"+apiPath(vhost), &consumers); err != nil {
+		return nil, err
 	}
+	return consumers, nil
+}
 
-	qs := &queueSorter{}
-	qs.Sort(mapExtendedQueues)
+/*
+queueProperties calculates the properties for all the given queues and sorts them by warnings/errors.
+
+all the queues share the same consumers index so no further api call is made per queue
+*/
+func (p *Ops) queueProperties(queues []rabbithole.QueueInfo, consumers *ConsumerIndex) []QueueProperties {
+	mapExtendedQueues := EvaluateQueues(queues, consumers, p.Thresholds)
+	for i := range mapExtendedQueues {
+		mapExtendedQueues[i].ops = p
+	}
 
 	return mapExtendedQueues
 }
 
 /*
-Queue returns details about a queue from the api
+Vhosts returns a list of all vhosts in the current cluster and sorts them by warnings/errors
+
+Deprecated: use ListVhosts, Vhosts panics on any management api failure
 */
-func (p *Ops) Queue(vhost, queue string) QueueProperties {
-	client := p.client()
-	queueDetail, err := client.GetQueue(vhost, queue)
+func (p *Ops) Vhosts() []VhostProperties {
+	vhosts, err := p.ListVhosts()
 	if err != nil {
 		panic(err.Error())
 	}
+	return vhosts
+}
 
-	retQueue := &QueueProperties{
-		QueueInfo: *queueDetail,
-		Client:    client,
-	}
+/*
+Vhost is a small indirection which returns a vhost
 
-	retQueue.Calculate()
-	return *retQueue
+Deprecated: use GetVhost, Vhost panics on any management api failure
+*/
+func (p *Ops) Vhost(vhost string) rabbithole.VhostInfo {
+	vhostRet, err := p.GetVhost(vhost)
+	if err != nil {
+		panic(err.Error())
+	}
+	return vhostRet
 }
 
 /*
-Queues returns all the queues from a vhost along with detailed information about them
+Nodes returns information about the current cluster individual nodes status
+
+Deprecated: use ListNodes, Nodes panics on any management api failure
 */
-func (p *Ops) Queues(vhost string) []QueueProperties {
-	client := p.client()
-	queues, err := client.ListQueuesIn(vhost)
+func (p *Ops) Nodes() []NodeProperties {
+	nodes, err := p.ListNodes()
 	if err != nil {
 		panic(err.Error())
 	}
+	return nodes
+}
 
-	var mapExtendedQueues []QueueProperties
+/*
+AccumulationQueues returns the most offending queues which can be a risk for the
+cluster health
 
-	for _, q := range queues {
-		extQueue := new(QueueProperties)
-		extQueue.QueueInfo = q
-		extQueue.Client = client
-		extQueue.Calculate()
+Deprecated: use ListAccumulationQueues, AccumulationQueues panics on any management api failure
+*/
+func (p *Ops) AccumulationQueues() []QueueProperties {
+	queues, err := p.ListAccumulationQueues()
+	if err != nil {
+		panic(err.Error())
+	}
+	return queues
+}
+
+/*
+Queue returns details about a queue from the api
 
-		mapExtendedQueues = append(mapExtendedQueues, *extQueue)
+Deprecated: use GetQueue, Queue panics on any management api failure
+*/
+func (p *Ops) Queue(vhost, queue string) QueueProperties {
+	queueRet, err := p.GetQueue(vhost, queue)
+	if err != nil {
+		panic(err.Error())
 	}
+	return queueRet
+}
 
-	qs := &queueSorter{}
-	qs.Sort(mapExtendedQueues)
+/*
+Queues returns all the queues from a vhost along with detailed information about them
 
-	return mapExtendedQueues
+Deprecated: use ListQueues, Queues panics on any management api failure
+*/
+func (p *Ops) Queues(vhost string) []QueueProperties {
+	queues, err := p.ListQueues(vhost)
+	if err != nil {
+		panic(err.Error())
+	}
+	return queues
 }
 
 /*
@@ -0,0 +1,48 @@
+package rabbitmonit_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+/*
+BenchmarkListAccumulationQueues checks that a poll costs one queue listing and one consumer listing,
+however many queues there are
+*/
+func BenchmarkListAccumulationQueues(b *testing.B) {
+	for _, n := range []int{1, 50, 500} {
+		b.Run(fmt.Sprintf("queues=%d", n), func(b *testing.B) {
+			var f fakeapi.Fixtures
+			for i := 0; i < n; i++ {
+				name := fmt.Sprintf("queue-%d", i)
+				f.Queues = append(f.Queues, rabbithole.QueueInfo{Name: name, Vhost: "/", State: "running", Durable: true, MessagesUnack: 5})
+				f.Consumers = append(f.Consumers, rabbithole.ConsumerInfo{ConsumerTag: name, PrefetchCount: 10, Queue: rabbithole.QueueDetail{Name: name, Vhost: "/"}})
+			}
+			srv := fakeapi.NewServer(f)
+			defer srv.Close()
+			ops := srv.Ops()
+
+			var requests int
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				srv.ResetRequests()
+				queues, err := ops.ListAccumulationQueues()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(queues) != n {
+					b.Fatalf("got %d queues, want %d", len(queues), n)
+				}
+				got := srv.TotalRequests()
+				if got != 2 {
+					b.Fatalf("%d requests for %d queues, want 2 (queues and consumers)", got, n)
+				}
+				requests += got
+			}
+			b.ReportMetric(float64(requests)/float64(b.N), "requests/op")
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package rabbitmonit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+/*
+Thresholds groups all the limits used when raising warnings and errors for queues, vhosts, nodes,
+exchanges, connections, channels and consumers
+*/
+type Thresholds struct {
+	Queue      QueueThresholds      `json:"queue" yaml:"queue"`
+	Vhost      VhostThresholds      `json:"vhost" yaml:"vhost"`
+	Node       NodeThresholds       `json:"node" yaml:"node"`
+	Exchange   ExchangeThresholds   `json:"exchange" yaml:"exchange"`
+	Connection ConnectionThresholds `json:"connection" yaml:"connection"`
+	Channel    ChannelThresholds    `json:"channel" yaml:"channel"`
+	Consumer   ConsumerThresholds   `json:"consumer" yaml:"consumer"`
+
+	// QueueOverrides are evaluated in order, the first one matching a queue replaces some of the Queue limits
+	QueueOverrides []QueueOverride `json:"queue_overrides" yaml:"queue_overrides"`
+}
+
+/*
+QueueThresholds holds the limits used by QueueProperties.Calculate
+*/
+type QueueThresholds struct {
+	RdyWarning            int     `json:"rdy_warning" yaml:"rdy_warning"`                         // ready messages above this raise a warning
+	RdyError              int     `json:"rdy_error" yaml:"rdy_error"`                             // ready messages above this raise an error
+	ListenerWarning       int     `json:"listener_warning" yaml:"listener_warning"`               // consumers at or below this raise a warning when messages are ready
+	ListenerError         int     `json:"listener_error" yaml:"listener_error"`                   // consumers at or below this raise an error when messages are ready
+	UtilisationWarning    float64 `json:"utilisation_warning" yaml:"utilisation_warning"`         // consumer utilisation below this raises a warning when messages are ready
+	UtilisationError      float64 `json:"utilisation_error" yaml:"utilisation_error"`             // consumer utilisation below this raises an error when messages are ready
+	IntakeRate            float64 `json:"intake_rate" yaml:"intake_rate"`                         // ready messages growth rate above this raises the intake alert
+	ConsumptionLowWarning float64 `json:"consumption_low_warning" yaml:"consumption_low_warning"` // publish/deliver rate difference above this raises a warning
+	ConsumptionLowError   float64 `json:"consumption_low_error" yaml:"consumption_low_error"`     // publish/deliver rate difference above this raises an error
+}
+
+/*
+VhostThresholds holds the limits used by VhostProperties.Calculate
+*/
+type VhostThresholds struct {
+	RdyWarning            int     `json:"rdy_warning" yaml:"rdy_warning"`                         // ready messages above this raise a warning
+	RdyError              int     `json:"rdy_error" yaml:"rdy_error"`                             // ready messages above this raise an error
+	ConsumptionLowWarning float64 `json:"consumption_low_warning" yaml:"consumption_low_warning"` // publish/deliver rate difference above this raises a warning
+	ConsumptionLowError   float64 `json:"consumption_low_error" yaml:"consumption_low_error"`     // publish/deliver rate difference above this raises an error
+}
+
+/*
+NodeThresholds holds the percentages used by NodeProperties.Calculate
+*/
+type NodeThresholds struct {
+	FdWarning   float64 `json:"fd_warning" yaml:"fd_warning"`
+	FdError     float64 `json:"fd_error" yaml:"fd_error"`
+	ErlWarning  float64 `json:"erl_warning" yaml:"erl_warning"`
+	ErlError    float64 `json:"erl_error" yaml:"erl_error"`
+	MemWarning  float64 `json:"mem_warning" yaml:"mem_warning"`
+	MemError    float64 `json:"mem_error" yaml:"mem_error"`
+	HddWarning  float64 `json:"hdd_warning" yaml:"hdd_warning"`
+	HddError    float64 `json:"hdd_error" yaml:"hdd_error"`
+	SockWarning float64 `json:"sock_warning" yaml:"sock_warning"`
+	SockError   float64 `json:"sock_error" yaml:"sock_error"`
+}
+
+/*
+ExchangeThresholds holds the rates used by ExchangeProperties.Calculate
+*/
+type ExchangeThresholds struct {
+	UnroutableWarning float64 `json:"unroutable_warning" yaml:"unroutable_warning"` // returned plus dropped messages per second above this raise a warning
+	UnroutableError   float64 `json:"unroutable_error" yaml:"unroutable_error"`     // returned plus dropped messages per second above this raise an error
+}
+
+/*
+ConnectionThresholds holds the limits used by ConnectionProperties.Calculate
+*/
+type ConnectionThresholds struct {
+	ChannelsWarning int     `json:"channels_warning" yaml:"channels_warning"` // open channels above this raise a warning
+	ChannelsError   int     `json:"channels_error" yaml:"channels_error"`     // open channels above this raise an error
+	IdleHours       float64 `json:"idle_hours" yaml:"idle_hours"`             // idle connections older than this raise a warning, 0 disables it
+	IdleRate        float64 `json:"idle_rate" yaml:"idle_rate"`               // bytes per second, sent and received, below which a connection is idle
+}
+
+/*
+ChannelThresholds holds the limits used by ChannelProperties.Calculate
+*/
+type ChannelThresholds struct {
+	SaturatedPolls int `json:"saturated_polls" yaml:"saturated_polls"` // consecutive polls at the prefetch limit raising a warning, 0 disables it
+}
+
+/*
+ConsumerThresholds holds the limits used by ConsumerProperties.Calculate
+*/
+type ConsumerThresholds struct {
+	PrefetchBufferSeconds float64 `json:"prefetch_buffer_seconds" yaml:"prefetch_buffer_seconds"` // prefetches holding more seconds of work than this raise a warning
+	PrefetchMinimum       int     `json:"prefetch_minimum" yaml:"prefetch_minimum"`               // prefetches up to this never raise the warning
+}
+
+/*
+DefaultThresholds returns the limits rabbit-monit has always been using
+*/
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Queue:      DefaultQueueThresholds(),
+		Vhost:      DefaultVhostThresholds(),
+		Node:       DefaultNodeThresholds(),
+		Exchange:   DefaultExchangeThresholds(),
+		Connection: DefaultConnectionThresholds(),
+		Channel:    DefaultChannelThresholds(),
+		Consumer:   DefaultConsumerThresholds(),
+	}
+}
+
+/*
+DefaultQueueThresholds returns the default queue limits
+*/
+func DefaultQueueThresholds() QueueThresholds {
+	return QueueThresholds{
+		RdyWarning:            0,
+		RdyError:              100,
+		ListenerWarning:       3,
+		ListenerError:         0,
+		UtilisationWarning:    70,
+		UtilisationError:      30,
+		IntakeRate:            1,
+		ConsumptionLowWarning: 5,
+		ConsumptionLowError:   10,
+	}
+}
+
+/*
+DefaultVhostThresholds returns the default vhost limits
+*/
+func DefaultVhostThresholds() VhostThresholds {
+	return VhostThresholds{
+		RdyWarning:            0,
+		RdyError:              1000,
+		ConsumptionLowWarning: 5,
+		ConsumptionLowError:   10,
+	}
+}
+
+/*
+DefaultNodeThresholds returns the default node limits
+*/
+func DefaultNodeThresholds() NodeThresholds {
+	return NodeThresholds{
+		FdWarning:   80,
+		FdError:     90,
+		ErlWarning:  80,
+		ErlError:    90,
+		MemWarning:  85,
+		MemError:    90,
+		HddWarning:  80,
+		HddError:    90,
+		SockWarning: 80,
+		SockError:   90,
+	}
+}
+
+/*
+DefaultExchangeThresholds returns the default exchange limits
+*/
+func DefaultExchangeThresholds() ExchangeThresholds {
+	return ExchangeThresholds{
+		UnroutableWarning: 0,
+		UnroutableError:   1,
+	}
+}
+
+/*
+DefaultConnectionThresholds returns the default connection limits
+*/
+func DefaultConnectionThresholds() ConnectionThresholds {
+	return ConnectionThresholds{
+		ChannelsWarning: 100,
+		ChannelsError:   1000,
+		IdleHours:       24,
+		IdleRate:        10,
+	}
+}
+
+/*
+DefaultChannelThresholds returns the default channel limits
+*/
+func DefaultChannelThresholds() ChannelThresholds {
+	return ChannelThresholds{
+		SaturatedPolls: 3,
+	}
+}
+
+/*
+DefaultConsumerThresholds returns the default consumer limits
+*/
+func DefaultConsumerThresholds() ConsumerThresholds {
+	return ConsumerThresholds{
+		PrefetchBufferSeconds: 60,
+		PrefetchMinimum:       100,
+	}
+}
+
+/*
+LoadThresholds reads the thresholds from a json (.json extension) or yaml file.
+
+values missing from the file keep their defaults
+*/
+func LoadThresholds(path string) (*Thresholds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds := DefaultThresholds()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &thresholds)
+	} else {
+		err = yaml.Unmarshal(data, &thresholds)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := thresholds.Compile(); err != nil {
+		return nil, err
+	}
+
+	return &thresholds, nil
+}
+
+/*
+Compile validates and prepares the patterns of all the queue overrides
+*/
+func (t *Thresholds) Compile() error {
+	for i := range t.QueueOverrides {
+		if err := t.QueueOverrides[i].Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+queue returns the queue limits, nil when t is nil
+*/
+func (t *Thresholds) queue() *QueueThresholds {
+	if t == nil {
+		return nil
+	}
+	return &t.Queue
+}
+
+/*
+queueOverrides returns the per queue overrides, nil when t is nil
+*/
+func (t *Thresholds) queueOverrides() []QueueOverride {
+	if t == nil {
+		return nil
+	}
+	return t.QueueOverrides
+}
+
+/*
+vhost returns the vhost limits, nil when t is nil
+*/
+func (t *Thresholds) vhost() *VhostThresholds {
+	if t == nil {
+		return nil
+	}
+	return &t.Vhost
+}
+
+/*
+node returns the node limits, nil when t is nil
+*/
+func (t *Thresholds) node() *NodeThresholds {
+	if t == nil {
+		return nil
+	}
+	return &t.Node
+}
+
+/*
+exchange returns the exchange limits, nil when t is nil
+*/
+func (t *Thresholds) exchange() *ExchangeThresholds {
+	if t == nil {
+		return nil
+	}
+	return &t.Exchange
+}
+
+/*
+connection returns the connection limits, nil when t is nil
+*/
+func (t *Thresholds) connection() *ConnectionThresholds {
+	if t == nil {
+		return nil
+	}
+	return &t.Connection
+}
+
+/*
+channel returns the channel limits, nil when t is nil
+*/
+func (t *Thresholds) channel() *ChannelThresholds {
+	if t == nil {
+		return nil
+	}
+	return &t.Channel
+}
+
+/*
+consumer returns the consumer limits, nil when t is nil
+*/
+func (t *Thresholds) consumer() *ConsumerThresholds {
+	if t == nil {
+		return nil
+	}
+	return &t.Consumer
+}
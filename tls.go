@@ -0,0 +1,55 @@
+package rabbitmonit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+)
+
+/*
+TLSConfig describes how to reach an https management api
+*/
+type TLSConfig struct {
+	CAFile             string `json:"ca_file" yaml:"ca_file"`                           // pem bundle of the authorities to trust, system pool when empty
+	CertFile           string `json:"cert_file" yaml:"cert_file"`                       // client certificate for mutual tls
+	KeyFile            string `json:"key_file" yaml:"key_file"`                         // key of the client certificate
+	ServerName         string `json:"server_name" yaml:"server_name"`                   // overrides the name checked against the server certificate
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"` // disables the server certificate verification, never use it in production
+}
+
+/*
+Config builds the *tls.Config described by t
+*/
+func (t *TLSConfig) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("rabbitmonit: no certificate found in " + t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, errors.New("rabbitmonit: both cert_file and key_file are needed for a client certificate")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
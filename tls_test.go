@@ -0,0 +1,125 @@
+package rabbitmonit_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+/*
+selfSigned writes a self signed certificate and its key in dir and returns their paths
+*/
+func selfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rabbit.test"},
+		DNSNames:              []string{"rabbit.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), time.Now())
+	writeFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})), time.Now())
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := selfSigned(t, dir)
+	notPem := filepath.Join(dir, "ca.txt")
+	writeFile(t, notPem, "not a certificate\n", time.Now())
+
+	tests := []struct {
+		name    string
+		config  rabbitmonit.TLSConfig
+		wantErr string
+	}{
+		{name: "defaults", config: rabbitmonit.TLSConfig{}},
+		{name: "ca file", config: rabbitmonit.TLSConfig{CAFile: certFile}},
+		{name: "missing ca file", config: rabbitmonit.TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}, wantErr: "missing.pem"},
+		{name: "bad ca file", config: rabbitmonit.TLSConfig{CAFile: notPem}, wantErr: "no certificate found in " + notPem},
+		{name: "client certificate", config: rabbitmonit.TLSConfig{CertFile: certFile, KeyFile: keyFile}},
+		{name: "cert without key", config: rabbitmonit.TLSConfig{CertFile: certFile}, wantErr: "both cert_file and key_file"},
+		{name: "key without cert", config: rabbitmonit.TLSConfig{KeyFile: keyFile}, wantErr: "both cert_file and key_file"},
+		{name: "key not matching", config: rabbitmonit.TLSConfig{CertFile: certFile, KeyFile: notPem}, wantErr: "key"},
+		{name: "insecure", config: rabbitmonit.TLSConfig{InsecureSkipVerify: true}},
+		{name: "server name", config: rabbitmonit.TLSConfig{ServerName: "rabbit.test"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := test.config.Config()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if config.InsecureSkipVerify != test.config.InsecureSkipVerify {
+				t.Errorf("InsecureSkipVerify = %v, want %v", config.InsecureSkipVerify, test.config.InsecureSkipVerify)
+			}
+			if config.ServerName != test.config.ServerName {
+				t.Errorf("ServerName = %q, want %q", config.ServerName, test.config.ServerName)
+			}
+			if (config.RootCAs != nil) != (test.config.CAFile != "") {
+				t.Errorf("RootCAs set = %v, want %v", config.RootCAs != nil, test.config.CAFile != "")
+			}
+			if len(config.Certificates) > 0 != (test.config.CertFile != "") {
+				t.Errorf("got %d client certificates", len(config.Certificates))
+			}
+		})
+	}
+}
+
+func TestTLSError(t *testing.T) {
+	srv := fakeapi.NewServer(fakeapi.Fixtures{})
+	defer srv.Close()
+
+	ops := srv.Ops()
+	ops.TLS = &rabbitmonit.TLSConfig{CertFile: "cert.pem"}
+	if _, err := ops.ListNodesContext(context.Background()); err == nil || !strings.Contains(err.Error(), "cert_file and key_file") {
+		t.Fatalf("err = %v, want the tls error", err)
+	}
+
+	// a supplied http client replaces the tls settings, they are not even validated
+	ops = srv.Ops()
+	ops.TLS = &rabbitmonit.TLSConfig{CertFile: "cert.pem"}
+	ops.HTTPClient = &http.Client{}
+	if _, err := ops.ListNodesContext(context.Background()); err != nil {
+		t.Fatalf("err = %v, want the tls settings ignored", err)
+	}
+}
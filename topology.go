@@ -0,0 +1,359 @@
+package rabbitmonit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/c-datculescu/rabbit-hole"
+)
+
+/*
+Topology is the message flow of a vhost: exchanges, queues and the edges between them
+*/
+type Topology struct {
+	Vhost     string             `json:"vhost"`
+	Exchanges []TopologyExchange `json:"exchanges"`
+	Queues    []TopologyQueue    `json:"queues"`
+	Edges     []TopologyEdge     `json:"edges"`
+}
+
+/*
+TopologyExchange is an exchange of the graph
+*/
+type TopologyExchange struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Internal bool   `json:"internal,omitempty"`
+	Missing  bool   `json:"missing,omitempty"` // referenced as dead letter or alternate exchange but not declared
+}
+
+/*
+TopologyQueue is a queue of the graph along with its current alert level
+*/
+type TopologyQueue struct {
+	Name  string   `json:"name"`
+	Level Level    `json:"level"`
+	Kinds []string `json:"kinds,omitempty"` // the flags raised at Level
+}
+
+/*
+EdgeKind tells how messages travel along an edge
+*/
+type EdgeKind string
+
+const (
+	EdgeBinding    EdgeKind = "binding"     // exchange to exchange or queue binding
+	EdgeDeadLetter EdgeKind = "dead_letter" // queue to its dead letter exchange, or queue when dead lettering through the default exchange
+	EdgeAlternate  EdgeKind = "alternate"   // exchange to its alternate exchange, for the unroutable messages
+)
+
+/*
+TopologyEdge connects two nodes of the graph. node types are "exchange" or "queue"
+*/
+type TopologyEdge struct {
+	Kind            EdgeKind `json:"kind"`
+	Source          string   `json:"source"`
+	SourceType      string   `json:"source_type"`
+	Destination     string   `json:"destination"`
+	DestinationType string   `json:"destination_type"`
+	RoutingKey      string   `json:"routing_key,omitempty"`
+}
+
+/*
+BuildTopology assembles the graph of every vhost found in the given objects, sorted by vhost.
+
+the bindings of the default exchange are implicit (every queue by its name) and left out. dead letter
+targets only come from the queue arguments, dead lettering configured through policies is not visible
+*/
+func BuildTopology(exchanges []ExchangeInfo, bindings []rabbithole.BindingInfo, queues []QueueProperties) []Topology {
+	builders := make(map[string]*topologyBuilder)
+	builder := func(vhost string) *topologyBuilder {
+		if b, ok := builders[vhost]; ok {
+			return b
+		}
+		b := &topologyBuilder{
+			topology:  Topology{Vhost: vhost, Exchanges: []TopologyExchange{}, Queues: []TopologyQueue{}, Edges: []TopologyEdge{}},
+			exchanges: make(map[string]int),
+		}
+		builders[vhost] = b
+		return b
+	}
+
+	for _, exchange := range exchanges {
+		if exchange.Name == "" {
+			continue
+		}
+		b := builder(exchange.Vhost)
+		b.exchange(exchange.Name, exchange.Type, exchange.Internal)
+		if alternate, ok := exchange.Arguments["alternate-exchange"].(string); ok && alternate != "" {
+			b.topology.Edges = append(b.topology.Edges, TopologyEdge{Kind: EdgeAlternate, Source: exchange.Name, SourceType: "exchange", Destination: alternate, DestinationType: "exchange"})
+		}
+	}
+
+	for i := range queues {
+		qp := &queues[i]
+		b := builder(qp.QueueInfo.Vhost)
+		b.topology.Queues = append(b.topology.Queues, TopologyQueue{
+			Name:  qp.QueueInfo.Name,
+			Level: qp.Level(),
+			Kinds: levelKinds(qp.Level(), qp.Error.Kinds(), qp.Warning.Kinds()),
+		})
+
+		dlx, ok := qp.QueueInfo.Arguments["x-dead-letter-exchange"].(string)
+		if !ok {
+			continue
+		}
+		routingKey, _ := qp.QueueInfo.Arguments["x-dead-letter-routing-key"].(string)
+		edge := TopologyEdge{Kind: EdgeDeadLetter, Source: qp.QueueInfo.Name, SourceType: "queue", Destination: dlx, DestinationType: "exchange", RoutingKey: routingKey}
+		if dlx == "" {
+			if routingKey == "" {
+				continue
+			}
+			edge.Destination, edge.DestinationType = routingKey, "queue"
+		}
+		b.topology.Edges = append(b.topology.Edges, edge)
+	}
+
+	for _, binding := range bindings {
+		if binding.Source == "" {
+			continue
+		}
+		b := builder(binding.Vhost)
+		b.topology.Edges = append(b.topology.Edges, TopologyEdge{
+			Kind:            EdgeBinding,
+			Source:          binding.Source,
+			SourceType:      "exchange",
+			Destination:     binding.Destination,
+			DestinationType: binding.DestinationType,
+			RoutingKey:      binding.RoutingKey,
+		})
+	}
+
+	topologies := make([]Topology, 0, len(builders))
+	for _, b := range builders {
+		for _, edge := range b.topology.Edges {
+			for _, end := range []struct{ name, kind string }{{edge.Source, edge.SourceType}, {edge.Destination, edge.DestinationType}} {
+				if end.kind == "exchange" {
+					if _, ok := b.exchanges[end.name]; !ok {
+						b.exchange(end.name, "", false)
+						b.topology.Exchanges[len(b.topology.Exchanges)-1].Missing = true
+					}
+				}
+			}
+		}
+		b.sort()
+		topologies = append(topologies, b.topology)
+	}
+	sort.Slice(topologies, func(i, j int) bool {
+		return topologies[i].Vhost < topologies[j].Vhost
+	})
+
+	return topologies
+}
+
+/*
+topologyBuilder assembles the topology of a single vhost
+*/
+type topologyBuilder struct {
+	topology  Topology
+	exchanges map[string]int
+}
+
+func (b *topologyBuilder) exchange(name, kind string, internal bool) {
+	b.exchanges[name] = len(b.topology.Exchanges)
+	b.topology.Exchanges = append(b.topology.Exchanges, TopologyExchange{Name: name, Type: kind, Internal: internal})
+}
+
+/*
+sort orders the nodes by name and the edges by source, so the exports do not change between two polls
+of the same cluster
+*/
+func (b *topologyBuilder) sort() {
+	t := &b.topology
+	sort.Slice(t.Exchanges, func(i, j int) bool { return t.Exchanges[i].Name < t.Exchanges[j].Name })
+	sort.Slice(t.Queues, func(i, j int) bool { return t.Queues[i].Name < t.Queues[j].Name })
+	sort.SliceStable(t.Edges, func(i, j int) bool {
+		first, second := t.Edges[i], t.Edges[j]
+		if first.SourceType != second.SourceType {
+			return first.SourceType < second.SourceType
+		}
+		if first.Source != second.Source {
+			return first.Source < second.Source
+		}
+		return first.Destination < second.Destination
+	})
+}
+
+/*
+GetTopology returns the topology of a vhost, or of every vhost when vhost is empty
+*/
+func (p *Ops) GetTopology(vhost string) ([]Topology, error) {
+	return p.GetTopologyContext(context.Background(), vhost)
+}
+
+/*
+GetTopologyContext is like GetTopology but aborts the management api calls once ctx is done
+*/
+func (p *Ops) GetTopologyContext(ctx context.Context, vhost string) ([]Topology, error) {
+	exchangesPath, bindingsPath := "exchanges", "bindings"
+	if vhost != "" {
+		exchangesPath += "/" + apiPath(vhost)
+		bindingsPath += "/" + apiPath(vhost)
+	}
+
+	var exchanges []ExchangeInfo
+	if err := p.get(ctx, "GetTopology", exchangesPath, &exchanges); err != nil {
+		return nil, err
+	}
+
+	var bindings []rabbithole.BindingInfo
+	if err := p.get(ctx, "GetTopology", bindingsPath, &bindings); err != nil {
+		return nil, err
+	}
+
+	var queues []QueueProperties
+	var err error
+	if vhost == "" {
+		queues, err = p.ListAccumulationQueuesContext(ctx)
+	} else {
+		queues, err = p.ListQueuesContext(ctx, vhost)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildTopology(exchanges, bindings, queues), nil
+}
+
+/*
+levelColors are the fill colours of the queues in the dot and mermaid exports
+*/
+var levelColors = map[Level]string{
+	LevelOK:      "#b7e1a1",
+	LevelWarning: "#ffd27f",
+	LevelError:   "#f28b82",
+}
+
+/*
+WriteTopologyJSON writes the topologies as json
+*/
+func WriteTopologyJSON(w io.Writer, topologies []Topology) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(topologies)
+}
+
+/*
+WriteTopologyDOT writes the topologies as a graphviz digraph, one cluster per vhost. exchanges are
+boxes (dashed when missing), queues are ellipses filled with the colour of their level, dead letter
+edges are red and alternate exchange edges dotted
+*/
+func WriteTopologyDOT(w io.Writer, topologies []Topology) error {
+	var sb strings.Builder
+	sb.WriteString("digraph topology {\n\trankdir=LR;\n\tnode [fontname=\"Helvetica\"];\n")
+	for i, t := range topologies {
+		fmt.Fprintf(&sb, "\tsubgraph cluster_%d {\n\t\tlabel=%s;\n", i, dotQuote("vhost "+t.Vhost))
+		for _, x := range t.Exchanges {
+			style := ""
+			if x.Missing {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(&sb, "\t\t%s [label=%s, shape=box%s];\n", dotQuote(nodeID(i, "exchange", x.Name)), dotQuote(exchangeLabel(x)), style)
+		}
+		for _, q := range t.Queues {
+			fmt.Fprintf(&sb, "\t\t%s [label=%s, shape=ellipse, style=filled, fillcolor=%s];\n", dotQuote(nodeID(i, "queue", q.Name)), dotQuote(q.Name), dotQuote(levelColors[q.Level]))
+		}
+		for _, e := range t.Edges {
+			attrs := []string{"label=" + dotQuote(e.RoutingKey)}
+			switch e.Kind {
+			case EdgeDeadLetter:
+				attrs = append(attrs, "color=red", "style=dashed")
+			case EdgeAlternate:
+				attrs = append(attrs, "style=dotted")
+			}
+			fmt.Fprintf(&sb, "\t\t%s -> %s [%s];\n", dotQuote(nodeID(i, e.SourceType, e.Source)), dotQuote(nodeID(i, e.DestinationType, e.Destination)), strings.Join(attrs, ", "))
+		}
+		sb.WriteString("\t}\n")
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+/*
+WriteTopologyMermaid writes the topologies as a mermaid flowchart, one subgraph per vhost, using the
+same conventions as WriteTopologyDOT
+*/
+func WriteTopologyMermaid(w io.Writer, topologies []Topology) error {
+	ids := make(map[string]string)
+	id := func(vhost int, kind, name string) string {
+		key := nodeID(vhost, kind, name)
+		if _, ok := ids[key]; !ok {
+			ids[key] = fmt.Sprintf("n%d", len(ids))
+		}
+		return ids[key]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	fmt.Fprintf(&sb, "\tclassDef ok fill:%s\n\tclassDef warning fill:%s\n\tclassDef error fill:%s\n\tclassDef missing stroke-dasharray:5 5\n",
+		levelColors[LevelOK], levelColors[LevelWarning], levelColors[LevelError])
+	for i, t := range topologies {
+		fmt.Fprintf(&sb, "\tsubgraph v%d[%s]\n", i, mermaidQuote("vhost "+t.Vhost))
+		for _, x := range t.Exchanges {
+			fmt.Fprintf(&sb, "\t\t%s[%s]", id(i, "exchange", x.Name), mermaidQuote(exchangeLabel(x)))
+			if x.Missing {
+				sb.WriteString(":::missing")
+			}
+			sb.WriteString("\n")
+		}
+		for _, q := range t.Queues {
+			fmt.Fprintf(&sb, "\t\t%s([%s]):::%s\n", id(i, "queue", q.Name), mermaidQuote(q.Name), q.Level)
+		}
+		for _, e := range t.Edges {
+			arrow := "-->"
+			switch e.Kind {
+			case EdgeDeadLetter:
+				arrow = "-.->"
+			case EdgeAlternate:
+				arrow = "==>"
+			}
+			label := ""
+			if e.RoutingKey != "" {
+				label = "|" + mermaidQuote(e.RoutingKey) + "|"
+			}
+			fmt.Fprintf(&sb, "\t\t%s %s%s %s\n", id(i, e.SourceType, e.Source), arrow, label, id(i, e.DestinationType, e.Destination))
+		}
+		sb.WriteString("\tend\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func nodeID(vhost int, kind, name string) string {
+	return fmt.Sprintf("%d/%s/%s", vhost, kind, name)
+}
+
+func exchangeLabel(x TopologyExchange) string {
+	if x.Type == "" {
+		return x.Name
+	}
+	return x.Name + " (" + x.Type + ")"
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+/*
+mermaidQuote quotes a label, mermaid has no escaping inside quotes besides its html entities
+*/
+func mermaidQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", " ").Replace(s) + `"`
+}
@@ -0,0 +1,109 @@
+package rabbitmonit_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c-datculescu/rabbit-hole"
+	"github.com/c-datculescu/rabbit-monit"
+	"github.com/c-datculescu/rabbit-monit/fakeapi"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+/*
+topologyFixtures holds two vhosts. /shop covers exchange to queue, exchange to exchange and default
+exchange bindings, dead lettering, an alternate exchange that is not declared and names with dots,
+slashes and quotes
+*/
+func topologyFixtures() fakeapi.Fixtures {
+	return fakeapi.Fixtures{
+		Exchanges: []rabbitmonit.ExchangeInfo{
+			{Name: "", Vhost: "/shop", Type: "direct"},
+			{Name: "orders.v1", Vhost: "/shop", Type: "topic", Arguments: map[string]interface{}{"alternate-exchange": "unrouted"}},
+			{Name: "audit/log", Vhost: "/shop", Type: "fanout", Internal: true},
+			{Name: "dlx", Vhost: "/shop", Type: "direct"},
+			{Name: "events", Vhost: "/other", Type: "fanout"},
+		},
+		Bindings: []rabbithole.BindingInfo{
+			{Source: "orders.v1", Vhost: "/shop", Destination: "orders.created", DestinationType: "queue", RoutingKey: "order.created"},
+			{Source: "orders.v1", Vhost: "/shop", Destination: "audit/log", DestinationType: "exchange", RoutingKey: "#"},
+			{Source: "audit/log", Vhost: "/shop", Destination: `audit "all"`, DestinationType: "queue"},
+			{Source: "", Vhost: "/shop", Destination: "orders.created", DestinationType: "queue", RoutingKey: "orders.created"},
+			{Source: "dlx", Vhost: "/shop", Destination: "orders.dead", DestinationType: "queue", RoutingKey: "orders.created"},
+			{Source: "events", Vhost: "/other", Destination: "events.log", DestinationType: "queue"},
+		},
+		Queues: []rabbithole.QueueInfo{
+			{Name: "orders.created", Vhost: "/shop", State: "running", Durable: true, MessagesRdy: 150, Messages: 150, MessagesPersistent: 150,
+				Arguments: map[string]interface{}{"x-dead-letter-exchange": "dlx"}},
+			{Name: `audit "all"`, Vhost: "/shop", State: "running", Durable: true},
+			{Name: "orders.dead", Vhost: "/shop", State: "running", Durable: true},
+			{Name: "events.log", Vhost: "/other", State: "running", Durable: true},
+		},
+	}
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file, run go test -update to review the change\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestTopologyExports(t *testing.T) {
+	srv := fakeapi.NewServer(topologyFixtures())
+	defer srv.Close()
+
+	topologies, err := srv.Ops().GetTopologyContext(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, export := range []struct {
+		golden string
+		write  func(*bytes.Buffer, []rabbitmonit.Topology) error
+	}{
+		{"topology.json", func(b *bytes.Buffer, t []rabbitmonit.Topology) error { return rabbitmonit.WriteTopologyJSON(b, t) }},
+		{"topology.dot", func(b *bytes.Buffer, t []rabbitmonit.Topology) error { return rabbitmonit.WriteTopologyDOT(b, t) }},
+		{"topology.mmd", func(b *bytes.Buffer, t []rabbitmonit.Topology) error { return rabbitmonit.WriteTopologyMermaid(b, t) }},
+	} {
+		t.Run(export.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := export.write(&buf, topologies); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, export.golden, buf.Bytes())
+		})
+	}
+}
+
+func TestTopologyVhost(t *testing.T) {
+	srv := fakeapi.NewServer(topologyFixtures())
+	defer srv.Close()
+
+	topologies, err := srv.Ops().GetTopologyContext(context.Background(), "/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topologies) != 1 || topologies[0].Vhost != "/other" {
+		t.Fatalf("got %d topologies, want only /other", len(topologies))
+	}
+	other := topologies[0]
+	if len(other.Exchanges) != 1 || len(other.Queues) != 1 || len(other.Edges) != 1 {
+		t.Errorf("/other = %+v, want its exchange, queue and binding only", other)
+	}
+}
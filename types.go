@@ -0,0 +1,142 @@
+package rabbitmonit
+
+/*
+the management api objects below are not covered by rabbithole, they only hold the fields
+rabbit-monit relies on
+*/
+
+/*
+OverviewInfo is the subset of /api/overview describing the cluster as a whole
+*/
+type OverviewInfo struct {
+	ManagementVersion string       `json:"management_version"`
+	RabbitMQVersion   string       `json:"rabbitmq_version"`
+	ErlangVersion     string       `json:"erlang_version"`
+	ClusterName       string       `json:"cluster_name"`
+	Node              string       `json:"node"`
+	QueueTotals       QueueTotals  `json:"queue_totals"`
+	ObjectTotals      ObjectTotals `json:"object_totals"`
+}
+
+/*
+QueueTotals are the message counters summed over all the queues
+*/
+type QueueTotals struct {
+	Messages      int `json:"messages"`
+	MessagesRdy   int `json:"messages_ready"`
+	MessagesUnack int `json:"messages_unacknowledged"`
+}
+
+/*
+ObjectTotals are the number of objects of each type in the cluster
+*/
+type ObjectTotals struct {
+	Consumers   int `json:"consumers"`
+	Queues      int `json:"queues"`
+	Exchanges   int `json:"exchanges"`
+	Connections int `json:"connections"`
+	Channels    int `json:"channels"`
+}
+
+/*
+ConnectionInfo is a client connection as listed by /api/connections
+*/
+type ConnectionInfo struct {
+	Name             string                 `json:"name"`
+	Vhost            string                 `json:"vhost"`
+	User             string                 `json:"user"`
+	Node             string                 `json:"node"`
+	State            string                 `json:"state"` // running, blocking, blocked, flow, closing...
+	Channels         int                    `json:"channels"`
+	Timeout          int                    `json:"timeout"`      // negotiated heartbeat in seconds, 0 means disabled
+	ConnectedAt      int64                  `json:"connected_at"` // milliseconds since the epoch
+	PeerHost         string                 `json:"peer_host"`
+	PeerPort         int                    `json:"peer_port"`
+	Protocol         string                 `json:"protocol"`
+	ClientProperties map[string]interface{} `json:"client_properties"`
+	RecvOct          int64                  `json:"recv_oct"`
+	SendOct          int64                  `json:"send_oct"`
+	RecvOctDetails   RateDetails            `json:"recv_oct_details"`
+	SendOctDetails   RateDetails            `json:"send_oct_details"`
+}
+
+/*
+RateDetails is the rate attached to a management api counter
+*/
+type RateDetails struct {
+	Rate float64 `json:"rate"`
+}
+
+/*
+ExchangeInfo is an exchange as listed by /api/exchanges. unlike rabbithole.ExchangeInfo it carries
+the message rates
+*/
+type ExchangeInfo struct {
+	Name         string                 `json:"name"` // empty for the default exchange
+	Vhost        string                 `json:"vhost"`
+	Type         string                 `json:"type"`
+	Durable      bool                   `json:"durable"`
+	AutoDelete   bool                   `json:"auto_delete"`
+	Internal     bool                   `json:"internal"`
+	Arguments    map[string]interface{} `json:"arguments"`
+	MessageStats ExchangeMessageStats   `json:"message_stats"`
+}
+
+/*
+ExchangeMessageStats are the message counters of an exchange. the unroutable counters are only
+reported by brokers collecting them per exchange, they stay 0 otherwise
+*/
+type ExchangeMessageStats struct {
+	PublishIn               int64       `json:"publish_in"`
+	PublishInDetails        RateDetails `json:"publish_in_details"`
+	PublishOut              int64       `json:"publish_out"`
+	PublishOutDetails       RateDetails `json:"publish_out_details"`
+	ReturnUnroutable        int64       `json:"return_unroutable"`
+	ReturnUnroutableDetails RateDetails `json:"return_unroutable_details"`
+	DropUnroutable          int64       `json:"drop_unroutable"`
+	DropUnroutableDetails   RateDetails `json:"drop_unroutable_details"`
+}
+
+/*
+ChannelInfo is a channel as listed by /api/channels
+*/
+type ChannelInfo struct {
+	Name                string              `json:"name"` // "<connection name> (<number>)"
+	Vhost               string              `json:"vhost"`
+	User                string              `json:"user"`
+	Node                string              `json:"node"`
+	Number              int                 `json:"number"`
+	State               string              `json:"state"`
+	ConnectionDetails   ConnectionDetails   `json:"connection_details"`
+	PrefetchCount       int                 `json:"prefetch_count"`        // per consumer limit, 0 means unlimited
+	GlobalPrefetchCount int                 `json:"global_prefetch_count"` // per channel limit, 0 means unlimited
+	ConsumerCount       int                 `json:"consumer_count"`
+	MessagesUnack       int                 `json:"messages_unacknowledged"`
+	MessagesUnconfirmed int                 `json:"messages_unconfirmed"`
+	Confirm             bool                `json:"confirm"`
+	Transactional       bool                `json:"transactional"`
+	MessageStats        ChannelMessageStats `json:"message_stats"`
+}
+
+/*
+ConnectionDetails identifies the connection a channel belongs to
+*/
+type ConnectionDetails struct {
+	Name     string `json:"name"`
+	PeerHost string `json:"peer_host"`
+	PeerPort int    `json:"peer_port"`
+}
+
+/*
+ChannelMessageStats are the message counters of a channel
+*/
+type ChannelMessageStats struct {
+	Ack               int64       `json:"ack"`
+	AckDetails        RateDetails `json:"ack_details"`
+	DeliverGet        int64       `json:"deliver_get"`
+	DeliverGetDetails RateDetails `json:"deliver_get_details"`
+	Publish           int64       `json:"publish"`
+	PublishDetails    RateDetails `json:"publish_details"`
+	Confirm           int64       `json:"confirm"`
+	ConfirmDetails    RateDetails `json:"confirm_details"`
+}
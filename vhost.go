@@ -8,10 +8,12 @@ import (
 VhostProperties extends rabbithole.VhostInfo with additional alerting/status values
 */
 type VhostProperties struct {
-	VhostInfo rabbithole.VhostInfo
-	Error     VhostAlert
-	Warning   VhostAlert
-	Stats     VhostStats
+	VhostInfo  rabbithole.VhostInfo
+	Cluster    string // name of the cluster the vhost belongs to, set by Fleet
+	Error      VhostAlert
+	Warning    VhostAlert
+	Stats      VhostStats
+	Thresholds *VhostThresholds // the limits used for alerting, nil means DefaultVhostThresholds
 }
 
 /*
@@ -30,6 +32,20 @@ type VhostAlert struct {
 	ConsumptionLow bool // the enqueue rate is bigger than the dequeue rate
 }
 
+/*
+Kinds returns the names of the flags that are set, Has excluded
+*/
+func (va VhostAlert) Kinds() []string {
+	var kinds []string
+	if va.Rdy {
+		kinds = append(kinds, "rdy")
+	}
+	if va.ConsumptionLow {
+		kinds = append(kinds, "consumption_low")
+	}
+	return kinds
+}
+
 /*
 Calculate runs all the statistics on the currently given vhost
 */
@@ -42,18 +58,29 @@ func (vp *VhostProperties) Calculate() {
 		alertConsumptionLow()
 }
 
+/*
+thresholds returns the limits to be used for the current vhost
+*/
+func (vp *VhostProperties) thresholds() VhostThresholds {
+	if vp.Thresholds != nil {
+		return *vp.Thresholds
+	}
+	return DefaultVhostThresholds()
+}
+
 /*
 alertRdy raises an alert/warning when messages ready exceed a certain limit
 
-threshold for alert is 1000
+threshold for alert is RdyError (default 1000)
 
-threshold for warning is 0
+threshold for warning is RdyWarning (default 0)
 */
 func (vp *VhostProperties) alertRdy() *VhostProperties {
-	if vp.VhostInfo.MessagesRdy > 1000 {
+	th := vp.thresholds()
+	if vp.VhostInfo.MessagesRdy > th.RdyError {
 		vp.Error.Has = true
 		vp.Error.Rdy = true
-	} else if vp.VhostInfo.MessagesRdy > 0 {
+	} else if vp.VhostInfo.MessagesRdy > th.RdyWarning {
 		vp.Warning.Has = true
 		vp.Warning.Rdy = true
 	}
@@ -64,18 +91,19 @@ func (vp *VhostProperties) alertRdy() *VhostProperties {
 /*
 alertConsumptionLow raises an alert/warning when consumption rate vs ingestion rate exceeds certain values
 
-threshold for alert is rate difference bigger than 10 and consuption is less than publishing
+threshold for alert is rate difference bigger than ConsumptionLowError (default 10) and consuption is less than publishing
 
-threshold for alert is rate difference bigger than 5 and consuption is less than publishing
+threshold for warning is rate difference bigger than ConsumptionLowWarning (default 5) and consuption is less than publishing
 */
 func (vp *VhostProperties) alertConsumptionLow() *VhostProperties {
 	rate := vp.VhostInfo.MessageStats.PublishDetails.Rate - vp.VhostInfo.MessageStats.DeliverDetails.Rate
 	vp.Stats.EnqueueDequeueDiff = rate
 	lowerThan := vp.VhostInfo.MessageStats.DeliverDetails.Rate < vp.VhostInfo.MessageStats.PublishDetails.Rate
-	if lowerThan && rate > 10 {
+	th := vp.thresholds()
+	if lowerThan && float64(rate) > th.ConsumptionLowError {
 		vp.Error.Has = true
 		vp.Error.ConsumptionLow = true
-	} else if lowerThan && rate > 5 {
+	} else if lowerThan && float64(rate) > th.ConsumptionLowWarning {
 		vp.Warning.Has = true
 		vp.Warning.ConsumptionLow = true
 	}